@@ -0,0 +1,197 @@
+package logging
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultAsyncQueueSize = 1024
+
+// OverflowPolicy decides what happens when the queue of an asynchronous
+// logger is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes the logging call wait until there is room in the
+	// queue. No entries are lost, at the cost of latency.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued entry to make room.
+	OverflowDropOldest
+	// OverflowDropNewest discards the entry being logged.
+	OverflowDropNewest
+)
+
+// AsyncConfig enables asynchronous logging: entries are encoded on the
+// calling goroutine into a bounded queue and written to the output by a
+// background goroutine. Call Logger.Sync or Logger.Close before the process
+// exits to flush the queue.
+type AsyncConfig struct {
+	// QueueSize is the maximum number of entries waiting to be written.
+	// Defaults to 1024.
+	QueueSize int
+	// Overflow decides what happens when the queue is full. Defaults to
+	// OverflowBlock.
+	Overflow OverflowPolicy
+}
+
+// asyncWriter queues every Write and performs it on a background goroutine.
+// slog handlers write each entry with a single Write call, so one queued
+// buffer is one log entry.
+type asyncWriter struct {
+	out      io.Writer
+	size     int
+	overflow OverflowPolicy
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	idle     *sync.Cond
+	queue    [][]byte
+	writing  bool
+	closed   bool
+	err      error
+
+	dropped atomic.Uint64
+	onDrop  func()
+	done    chan struct{}
+}
+
+// newAsyncWriter starts the background goroutine writing to out. onDrop, if
+// not nil, is called for every entry discarded because the queue was full.
+func newAsyncWriter(out io.Writer, config *AsyncConfig, onDrop func()) *asyncWriter {
+	w := &asyncWriter{
+		out:      out,
+		size:     config.QueueSize,
+		overflow: config.Overflow,
+		onDrop:   onDrop,
+		done:     make(chan struct{}),
+	}
+	if w.size <= 0 {
+		w.size = defaultAsyncQueueSize
+	}
+	w.notEmpty = sync.NewCond(&w.mu)
+	w.notFull = sync.NewCond(&w.mu)
+	w.idle = sync.NewCond(&w.mu)
+
+	go w.run()
+	return w
+}
+
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	for len(w.queue) >= w.size && !w.closed {
+		switch w.overflow {
+		case OverflowDropNewest:
+			w.mu.Unlock()
+			w.drop()
+			return len(p), nil
+		case OverflowDropOldest:
+			w.queue = w.queue[1:]
+			w.drop()
+		default:
+			w.notFull.Wait()
+		}
+	}
+	// Once closed, possibly while waiting for room, nothing drains the queue
+	// anymore.
+	if w.closed {
+		w.mu.Unlock()
+		return w.out.Write(p)
+	}
+
+	w.queue = append(w.queue, bytes.Clone(p))
+	w.notEmpty.Signal()
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.done)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for {
+		for len(w.queue) == 0 && !w.closed {
+			w.notEmpty.Wait()
+		}
+		if len(w.queue) == 0 {
+			return
+		}
+
+		batch := w.queue
+		w.queue = nil
+		w.writing = true
+		w.notFull.Broadcast()
+		w.mu.Unlock()
+
+		var err error
+		for _, entry := range batch {
+			if _, writeErr := w.out.Write(entry); writeErr != nil && err == nil {
+				err = writeErr
+			}
+		}
+
+		w.mu.Lock()
+		w.writing = false
+		if w.err == nil {
+			w.err = err
+		}
+		w.idle.Broadcast()
+	}
+}
+
+// Sync blocks until every queued entry has been written, then syncs the
+// output if it supports it. It returns the first write error since the
+// previous Sync.
+func (w *asyncWriter) Sync() error {
+	w.mu.Lock()
+	for len(w.queue) != 0 || w.writing {
+		w.idle.Wait()
+	}
+	err := w.err
+	w.err = nil
+	w.mu.Unlock()
+
+	syncWriter(w.out)
+	return err
+}
+
+// Close flushes the queue and stops the background goroutine. Entries
+// written after Close are written synchronously.
+func (w *asyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.notEmpty.Signal()
+	w.notFull.Broadcast()
+	w.mu.Unlock()
+
+	<-w.done
+	return w.Sync()
+}
+
+func (w *asyncWriter) drop() {
+	w.dropped.Add(1)
+	if w.onDrop != nil {
+		w.onDrop()
+	}
+}
+
+// Dropped returns the number of entries discarded because the queue was full.
+func (w *asyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// syncWriter syncs outputs like *os.File. Errors from outputs that cannot be
+// synced, e.g. stdout attached to a pipe, are not useful and are ignored.
+func syncWriter(out io.Writer) {
+	if s, ok := out.(interface{ Sync() error }); ok {
+		_ = s.Sync()
+	}
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// LogDepth logs at the given level with the source location of the caller
+// callDepth frames above the caller of LogDepth, so 0 is the caller itself.
+// Use it in helpers wrapping the Logger, so the entries point at the code
+// calling the helper instead of the helper.
+func (l *Logger) LogDepth(
+	ctx context.Context,
+	callDepth int,
+	level slog.Level,
+	msg string,
+	args ...any,
+) {
+	l.logPC(ctx, callerPC(callDepth+1), level, msg, args...)
+}
+
+// LogPC logs at the given level with the source location of pc, a program
+// counter as returned by runtime.Callers. Use it when the caller is found by
+// walking the stack, e.g. to skip the frames of a library.
+func (l *Logger) LogPC(
+	ctx context.Context,
+	pc uintptr,
+	level slog.Level,
+	msg string,
+	args ...any,
+) {
+	l.logPC(ctx, pc, level, msg, args...)
+}
+
+func (l *Logger) logPC(
+	ctx context.Context,
+	pc uintptr,
+	level slog.Level,
+	msg string,
+	args ...any,
+) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !l.Enabled(ctx, level) {
+		return
+	}
+
+	record := slog.NewRecord(time.Now(), level, msg, pc)
+	record.Add(args...)
+	_ = l.Handler().Handle(ctx, record)
+}
+
+// callerPC returns the program counter of the caller skip frames above the
+// caller of callerPC, so 0 is the function calling callerPC.
+func callerPC(skip int) uintptr {
+	var pcs [1]uintptr
+	runtime.Callers(skip+2, pcs[:])
+	return pcs[0]
+}
@@ -0,0 +1,259 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	consoleTimeFormat    = "15:04:05.000"
+	consoleMessageWidth  = 40
+	consoleLevelWidth    = 6
+	consoleStackIndent   = "    "
+	consoleTracePrefix   = "/traces/"
+	consoleGoogleLogging = "logging.googleapis.com/"
+
+	ansiReset   = "\033[0m"
+	ansiFaint   = "\033[2m"
+	ansiBold    = "\033[1m"
+	ansiRed     = "\033[31m"
+	ansiYellow  = "\033[33m"
+	ansiBlue    = "\033[34m"
+	ansiMagenta = "\033[35m"
+	ansiCyan    = "\033[36m"
+)
+
+// consoleHandler writes human-readable, optionally colorized entries for
+// local development:
+//
+//	12:04:05.123 INFO   fetching appointments                    count=3 trace=0102030405060708090a0b0c0d0e0f10 (service/appointments.go:42)
+//
+// Stack traces are printed as indented multi-line text below the entry.
+type consoleHandler struct {
+	mu    *sync.Mutex
+	out   io.Writer
+	color bool
+
+	// attrs holds the attributes added with WithAttrs, already formatted.
+	attrs  []byte
+	groups []string
+}
+
+func newConsoleHandler(out io.Writer, color bool) *consoleHandler {
+	return &consoleHandler{
+		mu:    &sync.Mutex{},
+		out:   out,
+		color: color,
+	}
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, _ slog.Level) bool {
+	// The level decision is made by levelHandler.
+	return true
+}
+
+func (h *consoleHandler) Handle(_ context.Context, record slog.Record) error {
+	buf := &bytes.Buffer{}
+	var stack string
+
+	h.paint(buf, ansiFaint, record.Time.Format(consoleTimeFormat))
+	buf.WriteByte(' ')
+	name := consoleLevelName(record.Level)
+	h.paint(buf, consoleLevelColor(record.Level), fmt.Sprintf("%-*s", consoleLevelWidth, name))
+	buf.WriteByte(' ')
+	h.paint(buf, ansiBold, record.Message)
+
+	fields := &bytes.Buffer{}
+	fields.Write(h.attrs)
+	record.Attrs(func(a slog.Attr) bool {
+		if a.Key == "stacktrace" || a.Key == "stack_trace" {
+			stack = a.Value.String()
+			return true
+		}
+		h.appendAttr(fields, h.groups, a)
+		return true
+	})
+
+	if fields.Len() != 0 {
+		if pad := consoleMessageWidth - len(record.Message); pad > 0 {
+			buf.WriteString(strings.Repeat(" ", pad))
+		}
+		buf.Write(fields.Bytes())
+	}
+
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		if frame.File != "" {
+			buf.WriteByte(' ')
+			h.paint(buf, ansiFaint, fmt.Sprintf(
+				"(%s/%s:%d)",
+				filepath.Base(filepath.Dir(frame.File)),
+				filepath.Base(frame.File),
+				frame.Line,
+			))
+		}
+	}
+	buf.WriteByte('\n')
+
+	if stack != "" {
+		for _, line := range strings.Split(strings.TrimRight(stack, "\n"), "\n") {
+			buf.WriteString(consoleStackIndent)
+			h.paint(buf, ansiFaint, line)
+			buf.WriteByte('\n')
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.out.Write(buf.Bytes())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	buf := bytes.NewBuffer(append([]byte(nil), h.attrs...))
+	for _, a := range attrs {
+		h.appendAttr(buf, h.groups, a)
+	}
+
+	clone := *h
+	clone.attrs = buf.Bytes()
+	return &clone
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := *h
+	clone.groups = append(append([]string(nil), h.groups...), name)
+	return &clone
+}
+
+func (h *consoleHandler) appendAttr(buf *bytes.Buffer, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	switch {
+	case a.Key == "serviceContext", isErrorReportingAttr(a):
+		// Constant or Error Reporting specific fields, noise on a console.
+		return
+	case a.Key == consoleGoogleLogging+"trace":
+		trace := a.Value.String()
+		if i := strings.LastIndex(trace, consoleTracePrefix); i >= 0 {
+			trace = trace[i+len(consoleTracePrefix):]
+		}
+		a = slog.String("trace", trace)
+	case a.Key == consoleGoogleLogging+"spanId":
+		a = slog.String("span", fmt.Sprint(a.Value.Any()))
+	case a.Key == consoleGoogleLogging+"trace_sampled":
+		return
+	case strings.HasPrefix(a.Key, consoleGoogleLogging):
+		a.Key = strings.TrimPrefix(a.Key, consoleGoogleLogging)
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, child := range a.Value.Group() {
+			h.appendAttr(buf, groups, child)
+		}
+		return
+	}
+
+	buf.WriteByte(' ')
+	key := a.Key
+	if len(groups) != 0 {
+		key = strings.Join(groups, ".") + "." + a.Key
+	}
+	h.paint(buf, ansiCyan, key+"=")
+	buf.WriteString(consoleValue(a.Value))
+}
+
+func (h *consoleHandler) paint(buf *bytes.Buffer, color string, s string) {
+	if !h.color || color == "" {
+		buf.WriteString(s)
+		return
+	}
+	buf.WriteString(color)
+	buf.WriteString(s)
+	buf.WriteString(ansiReset)
+}
+
+func consoleValue(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindString:
+		return consoleQuote(v.String())
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		switch value := v.Any().(type) {
+		case json.RawMessage:
+			return string(value)
+		case error:
+			return consoleQuote(value.Error())
+		case fmt.Stringer:
+			return consoleQuote(value.String())
+		}
+		if encoded, err := json.Marshal(v.Any()); err == nil {
+			return string(encoded)
+		}
+	}
+	return consoleQuote(v.String())
+}
+
+// consoleQuote quotes strings that would otherwise be ambiguous on a line of
+// space separated key=value pairs.
+func consoleQuote(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+func consoleLevelName(level slog.Level) string {
+	switch level {
+	case TraceLevel:
+		return "TRACE"
+	case DPanicLevel:
+		return "DPANIC"
+	case PanicLevel:
+		return "PANIC"
+	case FatalLevel:
+		return "FATAL"
+	}
+	return level.String()
+}
+
+func consoleLevelColor(level slog.Level) string {
+	switch {
+	case level >= PanicLevel:
+		return ansiMagenta
+	case level >= ErrorLevel:
+		return ansiRed
+	case level >= WarnLevel:
+		return ansiYellow
+	case level >= InfoLevel:
+		return ansiBlue
+	}
+	return ansiFaint
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"log/slog"
+	"runtime"
+)
+
+// reportedErrorEventType marks a log entry as an error event for GCP Error
+// Reporting, regardless of whether its stack trace could be parsed.
+// https://cloud.google.com/error-reporting/docs/formatting-error-messages
+const reportedErrorEventType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// errorReportingAttrs returns the fields Error Reporting needs to pick up and
+// group an entry: the @type marker, context.reportLocation taken from the
+// record's caller and a stack_trace formatted like a Go panic, i.e. the error
+// text followed by a blank line and the goroutine trace.
+func errorReportingAttrs(record slog.Record, stack []byte) []slog.Attr {
+	errorText := record.Message
+	record.Attrs(func(a slog.Attr) bool {
+		if err, ok := a.Value.Any().(error); ok && a.Key == "error" {
+			errorText = err.Error()
+			return false
+		}
+		return true
+	})
+
+	attrs := []slog.Attr{
+		slog.String("@type", reportedErrorEventType),
+		slog.String("stack_trace", errorText+"\n\n"+trimStack(stack)),
+	}
+
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		attrs = append(attrs, slog.Group("context",
+			slog.Group("reportLocation",
+				slog.String("filePath", frame.File),
+				slog.Int("lineNumber", frame.Line),
+				slog.String("functionName", frame.Function),
+			),
+		))
+	}
+
+	return attrs
+}
+
+// isErrorReportingAttr reports whether the attribute is the @type marker or
+// the context.reportLocation group added by errorReportingAttrs, as opposed
+// to a field of the caller's own with the same key.
+func isErrorReportingAttr(a slog.Attr) bool {
+	switch a.Key {
+	case "@type":
+		return a.Value.Kind() == slog.KindString && a.Value.String() == reportedErrorEventType
+	case "context":
+		if a.Value.Kind() == slog.KindGroup {
+			for _, child := range a.Value.Group() {
+				if child.Key == "reportLocation" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
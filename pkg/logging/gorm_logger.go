@@ -4,6 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -16,14 +21,47 @@ type GormLogger struct {
 	LogLevel                  gormlogger.LogLevel
 	SlowThreshold             time.Duration // Slow SQL threshold
 	IgnoreRecordNotFoundError bool
+
+	// ParameterizedQueries logs the SQL with placeholders, normalized, instead
+	// of with the bound values interpolated. The bound values are logged in
+	// the "sql_params" field according to Params.
+	ParameterizedQueries bool
+	// Params controls how bound values are logged with ParameterizedQueries.
+	Params SQLParamsMode
+	// ParamsHashKey is the HMAC key used with SQLParamsHash.
+	ParamsHashKey []byte
+
+	// NPlusOneThreshold, if set, logs a warning when the same query, by
+	// fingerprint, runs more than this many times within a request, which
+	// hints at the N+1 pattern. Requests are tracked through the QueryStats
+	// of the context, see ContextWithQueryStats.
+	NPlusOneThreshold int
+
+	// captures holds a *gormCapture per statement context being traced. It
+	// is shared by the copies made by LogMode.
+	captures *sync.Map
+}
+
+// gormCapture hands the statement passed to ParamsFilter over to Trace. gorm
+// calls ParamsFilter, with the statement context, from the function given to
+// Trace, which registers the capture under that context while calling it.
+type gormCapture struct {
+	mu       sync.Mutex
+	sql      string
+	params   []any
+	captured bool
 }
 
 func NewGormLogger(logger *Logger) *GormLogger {
 	return &GormLogger{
 		Logger:                    logger,
 		LogLevel:                  gormlogger.Warn,
-		SlowThreshold:             200 * time.Millisecond,
+		SlowThreshold:             defaultSlowThreshold,
 		IgnoreRecordNotFoundError: true,
+		ParameterizedQueries:      true,
+		Params:                    SQLParamsRedact,
+		NPlusOneThreshold:         defaultNPlusOneThreshold,
+		captures:                  &sync.Map{},
 	}
 }
 
@@ -33,15 +71,43 @@ func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
 		LogLevel:                  level,
 		SlowThreshold:             l.SlowThreshold,
 		IgnoreRecordNotFoundError: l.IgnoreRecordNotFoundError,
+		ParameterizedQueries:      l.ParameterizedQueries,
+		Params:                    l.Params,
+		ParamsHashKey:             l.ParamsHashKey,
+		NPlusOneThreshold:         l.NPlusOneThreshold,
+		captures:                  l.captures,
 	}
 }
 
+// ParamsFilter implements gorm.ParamsFilter. It keeps the bound values out
+// of the SQL gorm hands to Trace when ParameterizedQueries is set.
+func (l *GormLogger) ParamsFilter(
+	ctx context.Context,
+	sql string,
+	params ...interface{},
+) (string, []interface{}) {
+	if l.captures != nil && isComparable(ctx) {
+		if c, ok := l.captures.Load(ctx); ok {
+			c := c.(*gormCapture)
+			c.mu.Lock()
+			if !c.captured {
+				c.sql, c.params, c.captured = sql, params, true
+			}
+			c.mu.Unlock()
+		}
+	}
+	if l.ParameterizedQueries {
+		return sql, nil
+	}
+	return sql, params
+}
+
 func (l *GormLogger) Info(ctx context.Context, str string, args ...interface{}) {
 	if l.LogLevel < gormlogger.Info {
 		return
 	}
 
-	l.Logger.DebugContext(ctx, fmt.Sprintf(str, args...))
+	l.Logger.LogPC(ctx, sqlCallerPC(), DebugLevel, fmt.Sprintf(str, args...))
 }
 
 func (l *GormLogger) Warn(ctx context.Context, str string, args ...interface{}) {
@@ -49,52 +115,148 @@ func (l *GormLogger) Warn(ctx context.Context, str string, args ...interface{})
 		return
 	}
 
-	l.Logger.WarnContext(ctx, fmt.Sprintf(str, args...))
+	l.Logger.LogPC(ctx, sqlCallerPC(), WarnLevel, fmt.Sprintf(str, args...))
 }
 
 func (l *GormLogger) Error(ctx context.Context, str string, args ...interface{}) {
 	if l.LogLevel < gormlogger.Error {
 		return
 	}
-	l.Logger.ErrorContext(ctx, fmt.Sprintf(str, args...))
+	l.Logger.LogPC(ctx, sqlCallerPC(), ErrorLevel, fmt.Sprintf(str, args...))
 }
 
 func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
-	if l.LogLevel <= 0 {
-		return
+	if l.IgnoreRecordNotFoundError && errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+
+	var extra []any
+	if tx := gormTxFromContext(ctx); tx != nil {
+		extra = append(extra, String(logFieldDBTxID, tx.id))
+	}
+
+	tracer := sqlTracer{
+		logErrors:         l.LogLevel >= gormlogger.Error,
+		logWarnings:       l.LogLevel >= gormlogger.Warn,
+		logAll:            l.LogLevel >= gormlogger.Info,
+		slowThreshold:     l.SlowThreshold,
+		parameterized:     l.ParameterizedQueries,
+		params:            l.Params,
+		hashKey:           l.ParamsHashKey,
+		nPlusOneThreshold: l.NPlusOneThreshold,
+	}
+	tracer.trace(ctx, l.Logger, time.Since(begin), err, func() sqlQuery {
+		return l.loadQuery(ctx, fc)
+	}, extra...)
+}
+
+// loadQuery calls fc and returns the statement. With ParameterizedQueries,
+// it uses the statement and bound values captured by ParamsFilter, which
+// also make for the better fingerprint.
+//
+// Statements traced concurrently with the same context, e.g. from an
+// errgroup, share a capture slot: only the first one registered uses it, and
+// only if the statement captured is the one fc returns.
+func (l *GormLogger) loadQuery(ctx context.Context, fc func() (string, int64)) sqlQuery {
+	var query sqlQuery
+	var capture *gormCapture
+	if l.captures != nil && isComparable(ctx) {
+		capture = &gormCapture{}
+		if _, loaded := l.captures.LoadOrStore(ctx, capture); loaded {
+			capture = nil
+		} else {
+			defer l.captures.Delete(ctx)
+		}
+	}
+
+	query.sql, query.rows = fc()
+	query.fingerprint = queryFingerprint(query.sql)
+	if capture == nil {
+		return query
+	}
+
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	if !capture.captured {
+		return query
+	}
+	if l.ParameterizedQueries {
+		// Without bound values, gorm's Explain returns the statement as is.
+		if capture.sql != query.sql {
+			return query
+		}
+		query.params = capture.params
+	}
+	query.fingerprint = queryFingerprint(capture.sql)
+	return query
+}
+
+// isComparable reports whether ctx can be used as a map key.
+func isComparable(ctx context.Context) bool {
+	return ctx != nil && reflect.TypeOf(ctx).Comparable()
+}
+
+var (
+	gormSourceDir    = gormDir()
+	packageSourceDir = packageDir()
+)
+
+// gormDir returns the directory of the gorm sources, or of all gorm.io
+// modules (including the drivers) when they share a parent, as gorm's
+// utils.FileWithLineNum does.
+func gormDir() string {
+	file, _ := runtime.FuncForPC(reflect.ValueOf(gorm.Open).Pointer()).FileLine(0)
+	dir := filepath.Dir(file)
+	if parent := filepath.Dir(dir); filepath.Base(parent) == "gorm.io" {
+		dir = parent
+	}
+	return filepath.ToSlash(dir) + "/"
+}
+
+func packageDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.ToSlash(filepath.Dir(file)) + "/"
+}
+
+// sqlFunctionSkipPrefixes are the packages between the code running a query
+// and the database/sql driver wrapper or PgxTracer.
+var sqlFunctionSkipPrefixes = []string{
+	"database/sql.",
+	"github.com/jmoiron/sqlx.",
+	"github.com/jackc/pgx/",
+}
+
+// sqlCallerPC returns the program counter of the code that ran the query,
+// i.e. the first frame outside of gorm, database/sql, pgx and this package.
+func sqlCallerPC() uintptr {
+	return clientCallerPC(sqlFunctionSkipPrefixes)
+}
+
+// clientCallerPC returns the program counter of the first frame outside of
+// gorm, this package and the packages matching skipPrefixes. Like gorm's
+// utils.FileWithLineNum, it keeps test files and skips generated files of
+// go-gorm/gen.
+func clientCallerPC(skipPrefixes []string) uintptr {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:])
+	for _, pc := range pcs[:n] {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		if strings.HasSuffix(frame.File, ".gen.go") || hasAnyPrefix(frame.Function, skipPrefixes) {
+			continue
+		}
+		if strings.HasSuffix(frame.File, "_test.go") ||
+			(!strings.HasPrefix(frame.File, gormSourceDir) && !strings.HasPrefix(frame.File, packageSourceDir)) {
+			return pc
+		}
 	}
-	elapsed := time.Since(begin)
-
-	switch {
-	case err != nil &&
-		l.LogLevel >= gormlogger.Error &&
-		(!l.IgnoreRecordNotFoundError || !errors.Is(err, gorm.ErrRecordNotFound)):
-		sql, rows := fc()
-		l.Logger.ErrorContext(
-			ctx,
-			"sql error trace",
-			Error(err),
-			Duration("duration", elapsed),
-			Int64("rows", rows),
-			String("sql", sql),
-		)
-	case l.SlowThreshold != 0 && elapsed > l.SlowThreshold && l.LogLevel >= gormlogger.Warn:
-		sql, rows := fc()
-		l.Logger.WarnContext(
-			ctx,
-			"sql slow query trace",
-			Duration("duration", elapsed),
-			Int64("rows", rows),
-			String("sql", sql),
-		)
-	case l.LogLevel >= gormlogger.Info:
-		sql, rows := fc()
-		l.Logger.DebugContext(
-			ctx,
-			"sql debug trace",
-			Duration("duration", elapsed),
-			Int64("rows", rows),
-			String("sql", sql),
-		)
+	return 0
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
 	}
+	return false
 }
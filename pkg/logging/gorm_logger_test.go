@@ -0,0 +1,304 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dentech-floss/logging/pkg/logging"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGormLoggerSourceLocation(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.InfoLevel,
+
+		Output: &buf,
+	})
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logging.NewGormLogger(logger)})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	buf.Reset()
+	var count int64
+	db.Table("missing").Count(&count)
+
+	var entry struct {
+		Message        string `json:"message"`
+		SourceLocation struct {
+			File     string `json:"file"`
+			Function string `json:"function"`
+		} `json:"logging.googleapis.com/sourceLocation"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON log %q: %v", buf.String(), err)
+	}
+	if entry.Message != "sql error trace" {
+		t.Fatalf("Expected sql error trace, got %q", entry.Message)
+	}
+	if filepath.Base(entry.SourceLocation.File) != "gorm_logger_test.go" {
+		t.Errorf("Expected the query in the test as source location, got %+v", entry.SourceLocation)
+	}
+}
+
+func TestGormLoggerParameterizedQueries(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.DebugLevel,
+
+		Output: &buf,
+	})
+
+	gormLogger := logging.NewGormLogger(logger)
+	gormLogger.Params = logging.SQLParamsHash
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormLogger})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	type Patient struct {
+		ID             uint
+		Name           string
+		PersonalNumber string
+	}
+	if err := db.AutoMigrate(&Patient{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	buf.Reset()
+	db = db.Debug()
+	db.Create(&Patient{Name: "Tove Svensson", PersonalNumber: "19850709-9805"})
+	db.Where("personal_number IN ?", []string{"19850709-9805", "19850709-9806"}).Find(&[]Patient{})
+
+	for _, secret := range []string{"Tove Svensson", "19850709-9805"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Fatalf("Expected bound values to stay out of the log, got: %s", buf.String())
+		}
+	}
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse JSON log %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got: %v", entries)
+	}
+
+	insert, query := entries[0], entries[1]
+	if insert["db_operation"] != "INSERT" || insert["db_table"] != "patients" || insert["rows"] != float64(1) {
+		t.Errorf("Expected structured fields for the insert, got: %v", insert)
+	}
+	if params, _ := insert["sql_params"].([]any); len(params) != 2 {
+		t.Errorf("Expected 2 hashed params, got: %v", insert["sql_params"])
+	}
+	if query["db_operation"] != "SELECT" || query["query_fingerprint"] == "" {
+		t.Errorf("Expected structured fields for the query, got: %v", query)
+	}
+	if sql, _ := query["sql"].(string); !strings.Contains(sql, "IN (?...)") {
+		t.Errorf("Expected normalized SQL, got: %q", sql)
+	}
+}
+
+func TestGormLoggerConcurrentQueries(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.DebugLevel,
+
+		Output: &buf,
+	})
+
+	db, err := gorm.Open(
+		sqlite.Open(filepath.Join(t.TempDir(), "test.db")),
+		&gorm.Config{Logger: logging.NewGormLogger(logger)},
+	)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	type Patient struct {
+		ID   uint
+		Name string
+	}
+	if err := db.AutoMigrate(&Patient{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	buf.Reset()
+	db = db.Debug()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := logging.ContextWithQueryStats(context.Background())
+			db.WithContext(ctx).Where("name = ? AND id > ?", "Tove Svensson", i).Find(&[]Patient{})
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("Expected 8 entries, got: %s", buf.String())
+	}
+	for _, line := range lines {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse JSON log %q: %v", line, err)
+		}
+		if params, _ := entry["sql_params"].([]any); len(params) != 2 {
+			t.Errorf("Expected the params of each query, got: %v", entry)
+		}
+	}
+}
+
+func TestGormLoggerQueryStats(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.InfoLevel,
+
+		Output: &buf,
+	})
+
+	gormLogger := logging.NewGormLogger(logger)
+	gormLogger.NPlusOneThreshold = 2
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormLogger})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	type Appointment struct {
+		ID        uint
+		PatientID uint
+	}
+	if err := db.AutoMigrate(&Appointment{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	buf.Reset()
+	ctx := logging.ContextWithQueryStats(context.Background())
+	for patientID := range 5 {
+		db.WithContext(ctx).Where("patient_id = ?", patientID).Find(&[]Appointment{})
+	}
+
+	stats := logging.QueryStatsFromContext(ctx)
+	if stats.Queries() != 5 || stats.Duration() <= 0 {
+		t.Errorf("Expected 5 queries with their duration, got %d in %v", stats.Queries(), stats.Duration())
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected a single N+1 warning, got: %v", lines)
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON log: %v", err)
+	}
+	if entry["severity"] != "WARNING" || entry["count"] != float64(3) || entry["db_table"] != "appointments" {
+		t.Errorf("Expected N+1 warning on the third query, got: %v", entry)
+	}
+}
+
+func TestGormTxPlugin(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.DebugLevel,
+
+		Output: &buf,
+	})
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logging.NewGormLogger(logger)})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.Use(logging.NewGormTxPlugin(logger)); err != nil {
+		t.Fatalf("Failed to use plugin: %v", err)
+	}
+	type Invoice struct {
+		ID     uint
+		Amount int
+	}
+	if err := db.AutoMigrate(&Invoice{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if _, err := db.DB(); err != nil {
+		t.Fatalf("Expected the *sql.DB to stay reachable: %v", err)
+	}
+
+	buf.Reset()
+	db = db.Debug()
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		tx.Create(&Invoice{Amount: 100})
+		tx.Create(&Invoice{Amount: 200})
+		return nil
+	})
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		tx.Create(&Invoice{Amount: 300})
+		return errors.New("declined")
+	})
+
+	var count int64
+	db.Model(&Invoice{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("Expected the second transaction to roll back, got %d invoices", count)
+	}
+
+	txIDs := map[string][]string{}
+	var committed, rolledBack map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse JSON log %q: %v", line, err)
+		}
+		id, _ := entry["db_tx_id"].(string)
+		msg, _ := entry["message"].(string)
+		if id != "" {
+			txIDs[id] = append(txIDs[id], msg)
+		}
+		switch msg {
+		case "sql transaction commit":
+			committed = entry
+		case "sql transaction rollback":
+			rolledBack = entry
+		}
+	}
+
+	if len(txIDs) != 2 {
+		t.Fatalf("Expected 2 transactions, got: %v", txIDs)
+	}
+	if committed == nil || committed["db_statements"] != float64(2) {
+		t.Errorf("Expected commit with 2 statements, got: %v", committed)
+	}
+	if rolledBack == nil || rolledBack["severity"] != "INFO" {
+		t.Errorf("Expected rollback at INFO, got: %v", rolledBack)
+	}
+	committedID, _ := committed["db_tx_id"].(string)
+	want := []string{"sql transaction begin", "sql debug trace", "sql debug trace", "sql transaction commit"}
+	if got := txIDs[committedID]; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected the transaction ID on every entry, got: %v", got)
+	}
+}
@@ -0,0 +1,235 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	logFieldDBTxID       = "db_tx_id"
+	logFieldDBStatements = "db_statements"
+
+	gormTxPluginName   = "logging:transactions"
+	gormTxCallbackName = "logging:transaction"
+)
+
+// GormTxPlugin is a gorm.Plugin logging the lifecycle of transactions: their
+// begin, commit and rollback, with a transaction ID, their duration and the
+// number of statements run. GormLogger adds the transaction ID to the entries
+// of the statements run within a transaction.
+//
+//	db.Use(logging.NewGormTxPlugin(logger))
+type GormTxPlugin struct {
+	Logger *Logger
+	// Level is the level of the begin and commit entries.
+	Level slog.Level
+	// RollbackLevel is the level of the rollback entries. Failing to begin,
+	// commit or roll back is logged at ErrorLevel.
+	RollbackLevel slog.Level
+}
+
+func NewGormTxPlugin(logger *Logger) *GormTxPlugin {
+	return &GormTxPlugin{
+		Logger:        logger,
+		Level:         DebugLevel,
+		RollbackLevel: InfoLevel,
+	}
+}
+
+func (p *GormTxPlugin) Name() string {
+	return gormTxPluginName
+}
+
+// Initialize wraps the connection pool of db so that the transactions it
+// begins are logged, and registers the callbacks putting the transaction
+// into the context of its statements.
+func (p *GormTxPlugin) Initialize(db *gorm.DB) error {
+	db.ConnPool = &gormTxConnPool{ConnPool: db.ConnPool, plugin: p}
+	if db.Statement != nil {
+		db.Statement.ConnPool = db.ConnPool
+	}
+
+	callbacks := db.Callback()
+	for _, register := range []func(string, func(*gorm.DB)) error{
+		callbacks.Create().Before("*").Register,
+		callbacks.Query().Before("*").Register,
+		callbacks.Update().Before("*").Register,
+		callbacks.Delete().Before("*").Register,
+		callbacks.Row().Before("*").Register,
+		callbacks.Raw().Before("*").Register,
+	} {
+		if err := register(gormTxCallbackName, gormTxCallback); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gormTxCallback puts the transaction a statement runs in into the statement
+// context, where GormLogger finds it, and counts the statement.
+func gormTxCallback(db *gorm.DB) {
+	tx, ok := db.Statement.ConnPool.(*gormTx)
+	if !ok {
+		return
+	}
+	tx.statements.Add(1)
+	if gormTxFromContext(db.Statement.Context) != tx {
+		db.Statement.Context = context.WithValue(db.Statement.Context, gormTxContextKey{}, tx)
+	}
+}
+
+type gormTxContextKey struct{}
+
+func gormTxFromContext(ctx context.Context) *gormTx {
+	if ctx == nil {
+		return nil
+	}
+	tx, _ := ctx.Value(gormTxContextKey{}).(*gormTx)
+	return tx
+}
+
+// gormTxConnPool wraps the connection pool of a gorm.DB, turning the
+// transactions it begins into gormTx.
+type gormTxConnPool struct {
+	gorm.ConnPool
+	plugin *GormTxPlugin
+}
+
+func (c *gormTxConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	var (
+		pool gorm.ConnPool
+		err  error
+	)
+	switch beginner := c.ConnPool.(type) {
+	case gorm.TxBeginner:
+		pool, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		pool, err = beginner.BeginTx(ctx, opts)
+	default:
+		err = gorm.ErrInvalidTransaction
+	}
+
+	log := c.plugin.Logger
+	if err != nil {
+		log.LogPC(ctx, sqlCallerPC(), ErrorLevel, "sql transaction begin failed", Error(err))
+		return nil, err
+	}
+
+	tx := &gormTx{
+		ConnPool:  pool,
+		plugin:    c.plugin,
+		ctx:       ctx,
+		id:        newTxID(),
+		startTime: time.Now(),
+	}
+	log.LogPC(ctx, sqlCallerPC(), c.plugin.Level, "sql transaction begin", String(logFieldDBTxID, tx.id))
+	return tx, nil
+}
+
+// GetDBConn implements gorm.GetDBConnector, so that gorm.DB.DB keeps working.
+func (c *gormTxConnPool) GetDBConn() (*sql.DB, error) {
+	switch pool := c.ConnPool.(type) {
+	case *sql.DB:
+		return pool, nil
+	case gorm.GetDBConnector:
+		return pool.GetDBConn()
+	}
+	return nil, gorm.ErrInvalidDB
+}
+
+// gormTx is a transaction begun through gormTxConnPool.
+type gormTx struct {
+	gorm.ConnPool
+	plugin    *GormTxPlugin
+	ctx       context.Context
+	id        string
+	startTime time.Time
+
+	statements atomic.Int64
+}
+
+func (tx *gormTx) Commit() error {
+	committer, ok := tx.ConnPool.(gorm.TxCommitter)
+	if !ok {
+		return gorm.ErrInvalidTransaction
+	}
+	err := committer.Commit()
+	if err != nil {
+		tx.log(ErrorLevel, "sql transaction commit failed", Error(err))
+	} else {
+		tx.log(tx.plugin.Level, "sql transaction commit")
+	}
+	return err
+}
+
+func (tx *gormTx) Rollback() error {
+	committer, ok := tx.ConnPool.(gorm.TxCommitter)
+	if !ok {
+		return gorm.ErrInvalidTransaction
+	}
+	err := committer.Rollback()
+	switch {
+	case errors.Is(err, sql.ErrTxDone):
+		// Rolling back after a commit, as deferred rollbacks do.
+	case err != nil:
+		tx.log(ErrorLevel, "sql transaction rollback failed", Error(err))
+	default:
+		tx.log(tx.plugin.RollbackLevel, "sql transaction rollback")
+	}
+	return err
+}
+
+// StmtContext implements gorm.Tx for prepared statements.
+func (tx *gormTx) StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	if t, ok := tx.ConnPool.(interface {
+		StmtContext(context.Context, *sql.Stmt) *sql.Stmt
+	}); ok {
+		return t.StmtContext(ctx, stmt)
+	}
+	return stmt
+}
+
+func (tx *gormTx) log(level slog.Level, msg string, args ...any) {
+	logTxEnd(tx.ctx, tx.plugin.Logger, level, msg, tx.id, tx.startTime, tx.statements.Load(), args...)
+}
+
+// logTxEnd logs the end of a transaction, by GormTxPlugin or the
+// database/sql driver wrapper.
+func logTxEnd(
+	ctx context.Context,
+	logger *Logger,
+	level slog.Level,
+	msg string,
+	id string,
+	startTime time.Time,
+	statements int64,
+	args ...any,
+) {
+	duration := time.Since(startTime)
+	logger.LogPC(
+		ctx,
+		sqlCallerPC(),
+		level,
+		msg,
+		append([]any{
+			String(logFieldDBTxID, id),
+			Duration("duration", duration),
+			Int64("duration_ms", duration.Milliseconds()),
+			Int64(logFieldDBStatements, statements),
+		}, args...)...,
+	)
+}
+
+func newTxID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
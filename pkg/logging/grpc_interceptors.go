@@ -0,0 +1,404 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	logTypeValueIncomingGRPCRequest = "incoming_grpc_request"
+	logTypeValueExternalGRPCRequest = "external_grpc_request"
+
+	logFieldGRPCService          = "grpc_service"
+	logFieldGRPCMethod           = "grpc_method"
+	logFieldGRPCClientService    = "grpc_client_service"
+	logFieldGRPCClientMethod     = "grpc_client_method"
+	logFieldGRPCCode             = "grpc_code"
+	logFieldGRPCPeer             = "peer"
+	logFieldGRPCRequest          = "grpc_request"
+	logFieldGRPCResponse         = "grpc_response"
+	logFieldGRPCMessage          = "grpc_message"
+	logFieldGRPCMessagesSent     = "grpc_messages_sent"
+	logFieldGRPCMessagesReceived = "grpc_messages_received"
+)
+
+type InterceptorOptions struct {
+	// LogPayloads adds the request and response messages to the call entry
+	// using Proto. For streams, every message is logged at DEBUG instead.
+	// Payloads pass through the redaction policy of the Logger like any other
+	// field, but think twice before enabling this for personal data.
+	LogPayloads bool
+
+	// CodeToLevel overrides the level picked for a call from its status code,
+	// see CodeToLevel.
+	CodeToLevel func(code codes.Code) slog.Level
+
+	// Skip, if set, disables the call entry for matching full method names,
+	// e.g. "/grpc.health.v1.Health/Check". The request-scoped Logger is still
+	// put into the context.
+	Skip func(fullMethod string) bool
+}
+
+// CodeToLevel is the default mapping from a gRPC status code to the level of
+// the call entry: INFO for success and cancellations, WARNING for errors
+// caused by the caller and ERROR for errors on the server side.
+func CodeToLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK, codes.Canceled:
+		return InfoLevel
+	case codes.InvalidArgument,
+		codes.NotFound,
+		codes.AlreadyExists,
+		codes.PermissionDenied,
+		codes.Unauthenticated,
+		codes.ResourceExhausted,
+		codes.FailedPrecondition,
+		codes.Aborted,
+		codes.OutOfRange,
+		codes.DeadlineExceeded:
+		return WarnLevel
+	}
+	return ErrorLevel
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor that puts a request-scoped
+// Logger and QueryStats into the context of the handler (see
+// LoggerFromContext) and emits one entry per call with its code, duration,
+// peer and the db_queries and db_time_ms fields.
+func UnaryServerInterceptor(
+	logger *Logger,
+	options *InterceptorOptions,
+) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		log := logger.With(grpcMethodAttrs(info.FullMethod, logFieldGRPCService, logFieldGRPCMethod)...)
+		ctx, stats := contextWithQueryStats(ContextWithLogger(ctx, log))
+
+		startTime := time.Now()
+		resp, err := handler(ctx, req)
+
+		if !options.skip(info.FullMethod) {
+			fields := grpcCallFields(logTypeValueIncomingGRPCRequest, err, time.Since(startTime), peerFromContext(ctx))
+			if options != nil && options.LogPayloads {
+				fields = appendPayload(fields, logFieldGRPCRequest, req)
+				fields = appendPayload(fields, logFieldGRPCResponse, resp)
+			}
+			fields = appendQueryStats(fields, stats)
+			log.LogAttrs(ctx, options.level(err), "served grpc call", fields...)
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor. The entry is emitted when the handler returns.
+func StreamServerInterceptor(
+	logger *Logger,
+	options *InterceptorOptions,
+) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		log := logger.With(grpcMethodAttrs(info.FullMethod, logFieldGRPCService, logFieldGRPCMethod)...)
+		ctx, stats := contextWithQueryStats(ContextWithLogger(ss.Context(), log))
+		stream := &serverStream{
+			ServerStream: ss,
+			ctx:          ctx,
+			log:          log,
+			payloads:     options != nil && options.LogPayloads,
+		}
+
+		startTime := time.Now()
+		err := handler(srv, stream)
+
+		if !options.skip(info.FullMethod) {
+			fields := grpcCallFields(logTypeValueIncomingGRPCRequest, err, time.Since(startTime), peerFromContext(ctx))
+			fields = append(
+				fields,
+				Int(logFieldGRPCMessagesSent, stream.sent),
+				Int(logFieldGRPCMessagesReceived, stream.received),
+			)
+			fields = appendQueryStats(fields, stats)
+			log.LogAttrs(ctx, options.level(err), "served grpc stream", fields...)
+		}
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor that emits one entry per
+// outgoing call with its code, duration and peer. Like LoggingTransport, it
+// prefers the Logger found in the context over the given one. The called
+// method is logged as grpc_client_service and grpc_client_method, apart from
+// the served one a request-scoped Logger carries.
+func UnaryClientInterceptor(
+	logger *Logger,
+	options *InterceptorOptions,
+) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply any,
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		log := clientLogger(ctx, logger, method)
+		ctx = ContextWithLogger(ctx, log)
+
+		var p peer.Peer
+		startTime := time.Now()
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+
+		if !options.skip(method) {
+			fields := grpcCallFields(logTypeValueExternalGRPCRequest, err, time.Since(startTime), &p)
+			if options != nil && options.LogPayloads {
+				fields = appendPayload(fields, logFieldGRPCRequest, req)
+				if err == nil {
+					fields = appendPayload(fields, logFieldGRPCResponse, reply)
+				}
+			}
+			log.LogAttrs(ctx, options.level(err), "called external grpc service", fields...)
+		}
+		return err
+	}
+}
+
+// StreamClientInterceptor is the streaming counterpart of
+// UnaryClientInterceptor. The entry is emitted once the stream ends, i.e.
+// when receiving returns io.EOF or an error, or, for client-streaming calls,
+// when the single response is received.
+func StreamClientInterceptor(
+	logger *Logger,
+	options *InterceptorOptions,
+) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		log := clientLogger(ctx, logger, method)
+		ctx = ContextWithLogger(ctx, log)
+
+		stream := &clientStream{
+			ctx:       ctx,
+			desc:      desc,
+			log:       log,
+			options:   options,
+			method:    method,
+			startTime: time.Now(),
+		}
+		cs, err := streamer(ctx, desc, cc, method, append(opts, grpc.Peer(&stream.peer))...)
+		if err != nil {
+			stream.finish(err)
+			return nil, err
+		}
+		stream.ClientStream = cs
+		return stream, nil
+	}
+}
+
+func (o *InterceptorOptions) skip(fullMethod string) bool {
+	return o != nil && o.Skip != nil && o.Skip(fullMethod)
+}
+
+func (o *InterceptorOptions) level(err error) slog.Level {
+	code := status.Code(err)
+	if o != nil && o.CodeToLevel != nil {
+		return o.CodeToLevel(code)
+	}
+	return CodeToLevel(code)
+}
+
+// grpcMethodAttrs splits a full method name, "/package.Service/Method", into
+// service and method fields with the given keys.
+func grpcMethodAttrs(fullMethod, serviceKey, methodKey string) []any {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return []any{String(methodKey, fullMethod)}
+	}
+	return []any{
+		String(serviceKey, service),
+		String(methodKey, method),
+	}
+}
+
+func clientLogger(ctx context.Context, logger *Logger, method string) *Logger {
+	log := LoggerFromContext(ctx)
+	if log == nil {
+		log = logger
+	}
+	return log.With(grpcMethodAttrs(method, logFieldGRPCClientService, logFieldGRPCClientMethod)...)
+}
+
+func grpcCallFields(
+	logType string,
+	err error,
+	duration time.Duration,
+	p *peer.Peer,
+) []slog.Attr {
+	fields := []slog.Attr{
+		Label("log_type", logType),
+		String(logFieldGRPCCode, status.Code(err).String()),
+		Duration("duration", duration),
+		Int64("duration_ms", duration.Milliseconds()),
+	}
+	if p != nil && p.Addr != nil {
+		fields = append(fields, String(logFieldGRPCPeer, p.Addr.String()))
+	}
+	if err != nil {
+		fields = append(fields, Error(err))
+	}
+	return fields
+}
+
+func peerFromContext(ctx context.Context) *peer.Peer {
+	p, _ := peer.FromContext(ctx)
+	return p
+}
+
+func appendPayload(fields []slog.Attr, key string, payload any) []slog.Attr {
+	if message, ok := payload.(proto.Message); ok && message != nil {
+		fields = append(fields, Proto(key, message))
+	}
+	return fields
+}
+
+// serverStream carries the context with the request-scoped Logger and
+// counts, and optionally logs, the messages of the stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	log      *Logger
+	payloads bool
+
+	sent     int
+	received int
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		if s.payloads {
+			s.logMessage("sent grpc stream message", m)
+		}
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+		if s.payloads {
+			s.logMessage("received grpc stream message", m)
+		}
+	}
+	return err
+}
+
+func (s *serverStream) logMessage(msg string, m any) {
+	s.log.LogAttrs(s.ctx, DebugLevel, msg, appendPayload(nil, logFieldGRPCMessage, m)...)
+}
+
+// clientStream counts, and optionally logs, the messages of the stream and
+// emits the call entry once the stream ends.
+type clientStream struct {
+	grpc.ClientStream
+	ctx       context.Context
+	desc      *grpc.StreamDesc
+	log       *Logger
+	options   *InterceptorOptions
+	method    string
+	startTime time.Time
+	peer      peer.Peer
+
+	// SendMsg and RecvMsg may be called concurrently from different
+	// goroutines.
+	mu       sync.Mutex
+	sent     int
+	received int
+	once     sync.Once
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.sent++
+		s.mu.Unlock()
+		s.logMessage("sent grpc stream message", m)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.mu.Lock()
+		s.received++
+		s.mu.Unlock()
+		s.logMessage("received grpc stream message", m)
+		// Without server streaming, the stream ends with its single response
+		// and receiving never returns io.EOF.
+		if !s.desc.ServerStreams {
+			s.finish(nil)
+		}
+	case errors.Is(err, io.EOF):
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *clientStream) logMessage(msg string, m any) {
+	if s.options != nil && s.options.LogPayloads {
+		s.log.LogAttrs(s.ctx, DebugLevel, msg, appendPayload(nil, logFieldGRPCMessage, m)...)
+	}
+}
+
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		if s.options.skip(s.method) {
+			return
+		}
+		s.mu.Lock()
+		sent, received := s.sent, s.received
+		s.mu.Unlock()
+
+		fields := grpcCallFields(logTypeValueExternalGRPCRequest, err, time.Since(s.startTime), &s.peer)
+		fields = append(
+			fields,
+			Int(logFieldGRPCMessagesSent, sent),
+			Int(logFieldGRPCMessagesReceived, received),
+		)
+		s.log.LogAttrs(s.ctx, s.options.level(err), "called external grpc stream", fields...)
+	})
+}
@@ -0,0 +1,286 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dentech-floss/logging/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCInterceptors(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.InfoLevel,
+
+		Output: &buf,
+	})
+	options := &logging.InterceptorOptions{LogPayloads: true}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor(logger, options)),
+		grpc.ChainStreamInterceptor(logging.StreamServerInterceptor(logger, options)),
+	)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("booking", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	go func() { _ = server.Serve(listener) }()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(logging.UnaryClientInterceptor(logger, options)),
+		grpc.WithChainStreamInterceptor(logging.StreamClientInterceptor(logger, options)),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client := healthpb.NewHealthClient(conn)
+
+	ctx := context.Background()
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "booking"}); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("Expected NotFound, got: %v", err)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	stream, err := client.Watch(watchCtx, &healthpb.HealthCheckRequest{Service: "booking"})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Fatalf("Expected Canceled, got: %v", err)
+	}
+
+	_ = conn.Close()
+	server.GracefulStop()
+
+	entries := map[string][]map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse JSON log %q: %v", line, err)
+		}
+		msg, _ := entry["message"].(string)
+		entries[msg] = append(entries[msg], entry)
+	}
+
+	served := entries["served grpc call"]
+	if len(served) != 2 {
+		t.Fatalf("Expected 2 served unary calls, got: %v", entries)
+	}
+	if served[0]["severity"] != "INFO" || served[0]["grpc_code"] != "OK" {
+		t.Errorf("Expected INFO/OK entry, got: %v", served[0])
+	}
+	if served[1]["severity"] != "WARNING" || served[1]["grpc_code"] != "NotFound" {
+		t.Errorf("Expected WARNING/NotFound entry, got: %v", served[1])
+	}
+	if served[0]["grpc_service"] != "grpc.health.v1.Health" || served[0]["grpc_method"] != "Check" {
+		t.Errorf("Expected service and method fields, got: %v", served[0])
+	}
+	if request, _ := served[0]["grpc_request"].(map[string]any); request["service"] != "booking" {
+		t.Errorf("Expected request payload, got: %v", served[0]["grpc_request"])
+	}
+	if _, ok := served[0]["peer"]; !ok {
+		t.Errorf("Expected peer field, got: %v", served[0])
+	}
+
+	called := entries["called external grpc service"]
+	if len(called) != 2 || called[1]["severity"] != "WARNING" {
+		t.Errorf("Expected 2 client entries, the second at WARNING, got: %v", called)
+	}
+
+	for _, msg := range []string{"served grpc stream", "called external grpc stream"} {
+		stream := entries[msg]
+		if len(stream) != 1 || stream[0]["grpc_code"] != "Canceled" {
+			t.Errorf("Expected one canceled %q entry, got: %v", msg, stream)
+		}
+	}
+}
+
+// streamingInputServer sums the payloads of a client-streaming call.
+type streamingInputServer struct {
+	testpb.UnimplementedTestServiceServer
+}
+
+func (streamingInputServer) StreamingInputCall(stream testpb.TestService_StreamingInputCallServer) error {
+	var size int32
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&testpb.StreamingInputCallResponse{AggregatedPayloadSize: size})
+		}
+		if err != nil {
+			return err
+		}
+		size += int32(len(req.GetPayload().GetBody()))
+	}
+}
+
+func TestGRPCInterceptorsClientStreaming(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.InfoLevel,
+
+		Output: &buf,
+	})
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainStreamInterceptor(logging.StreamServerInterceptor(logger, nil)))
+	testpb.RegisterTestServiceServer(server, streamingInputServer{})
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainStreamInterceptor(logging.StreamClientInterceptor(logger, nil)),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	stream, err := testpb.NewTestServiceClient(conn).StreamingInputCall(context.Background())
+	if err != nil {
+		t.Fatalf("StreamingInputCall failed: %v", err)
+	}
+	for _, body := range []string{"first", "second"} {
+		if err := stream.Send(&testpb.StreamingInputCallRequest{Payload: &testpb.Payload{Body: []byte(body)}}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv failed: %v", err)
+	}
+	if resp.GetAggregatedPayloadSize() != 11 {
+		t.Fatalf("Expected aggregated size 11, got: %d", resp.GetAggregatedPayloadSize())
+	}
+
+	var called []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse JSON log %q: %v", line, err)
+		}
+		if entry["message"] == "called external grpc stream" {
+			called = append(called, entry)
+		}
+	}
+	if len(called) != 1 {
+		t.Fatalf("Expected one client stream entry, got: %s", buf.String())
+	}
+	if called[0]["grpc_code"] != "OK" || called[0]["grpc_messages_sent"] != float64(2) || called[0]["grpc_messages_received"] != float64(1) {
+		t.Errorf("Expected OK entry with message counts, got: %v", called[0])
+	}
+}
+
+// forwardingServer checks the health of a service through client while
+// serving a unary call.
+type forwardingServer struct {
+	testpb.UnimplementedTestServiceServer
+	client healthpb.HealthClient
+}
+
+func (s forwardingServer) UnaryCall(ctx context.Context, _ *testpb.SimpleRequest) (*testpb.SimpleResponse, error) {
+	if _, err := s.client.Check(ctx, &healthpb.HealthCheckRequest{Service: "booking"}); err != nil {
+		return nil, err
+	}
+	return &testpb.SimpleResponse{}, nil
+}
+
+func TestGRPCInterceptorsClientCallInHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.InfoLevel,
+
+		Output: &buf,
+	})
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor(logger, nil)))
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(logging.UnaryClientInterceptor(logger, nil)),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("booking", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+	testpb.RegisterTestServiceServer(server, forwardingServer{client: healthpb.NewHealthClient(conn)})
+	go func() { _ = server.Serve(listener) }()
+
+	if _, err := testpb.NewTestServiceClient(conn).UnaryCall(context.Background(), &testpb.SimpleRequest{}); err != nil {
+		t.Fatalf("UnaryCall failed: %v", err)
+	}
+
+	var nested map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse JSON log %q: %v", line, err)
+		}
+		if entry["message"] != "called external grpc service" || entry["grpc_client_method"] != "Check" {
+			continue
+		}
+		nested = entry
+		for _, key := range []string{`"grpc_service"`, `"grpc_method"`} {
+			if strings.Count(line, key) != 1 {
+				t.Errorf("Expected %s once, got: %s", key, line)
+			}
+		}
+	}
+	if nested == nil {
+		t.Fatalf("Expected an entry for the call made by the handler, got: %s", buf.String())
+	}
+	if nested["grpc_service"] != "grpc.testing.TestService" || nested["grpc_method"] != "UnaryCall" ||
+		nested["grpc_client_service"] != "grpc.health.v1.Health" {
+		t.Errorf("Expected the served and the called method, got: %v", nested)
+	}
+}
@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	logFieldRequestBody  = "request_body"
+	logFieldResponseBody = "response_body"
+)
+
+// bodyCapture keeps the first bytes of a body, up to its limit, and counts
+// all of them. It is safe for concurrent use, as a request body may be read
+// by the transport while RoundTrip returns.
+type bodyCapture struct {
+	limit int
+
+	mu   sync.Mutex
+	head []byte
+	size int64
+}
+
+func (c *bodyCapture) write(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size += int64(len(p))
+	if room := c.limit - len(c.head); room > 0 {
+		c.head = append(c.head, p[:min(room, len(p))]...)
+	}
+}
+
+// fields returns the fields of the body, named after key: its content type
+// and size, and its content for JSON and text bodies. Complete JSON bodies
+// are logged as structured JSON, form bodies with the query parameters of the
+// redaction replaced. Binary and multipart bodies are only summarized.
+func (c *bodyCapture) fields(key string, contentType string, redaction *HTTPRedaction) []any {
+	c.mu.Lock()
+	head, size := string(c.head), c.size
+	c.mu.Unlock()
+
+	if contentType == "" && size > 0 {
+		contentType = http.DetectContentType([]byte(head))
+	}
+	fields := []any{
+		String(key+"_content_type", contentType),
+		Int64(key+"_size", size),
+	}
+	if size == 0 {
+		return fields
+	}
+	truncated := int64(len(head)) < size
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case isJSONMediaType(mediaType):
+		if !truncated && json.Valid([]byte(head)) {
+			return append(fields, Any(key, json.RawMessage(head)))
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		head = redaction.query(head)
+	case strings.HasPrefix(mediaType, "text/") || isXMLMediaType(mediaType):
+	default:
+		return fields
+	}
+
+	fields = append(fields, String(key, head))
+	if truncated {
+		fields = append(fields, slog.Bool(key+"_truncated", true))
+	}
+	return fields
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func isXMLMediaType(mediaType string) bool {
+	return mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// captureReadCloser tees what is read from a body into a bodyCapture, and
+// calls done once the body is read to the end or closed.
+type captureReadCloser struct {
+	io.ReadCloser
+	capture *bodyCapture
+	done    func()
+	once    sync.Once
+}
+
+func (r *captureReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.capture.write(p[:n])
+	if err == io.EOF {
+		r.finish()
+	}
+	return n, err
+}
+
+func (r *captureReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.finish()
+	return err
+}
+
+func (r *captureReadCloser) finish() {
+	if r.done != nil {
+		r.once.Do(r.done)
+	}
+}
@@ -0,0 +1,224 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	logTypeValueIncomingRequest = "incoming_request"
+
+	logFieldHTTPRequest = "httpRequest"
+)
+
+type MiddlewareOptions struct {
+	// Skip, if set, disables the request entry for matching requests, e.g.
+	// health checks. The request-scoped Logger and panic recovery still apply.
+	Skip func(req *http.Request) bool
+	// TrustedHops is the number of entries at the end of X-Forwarded-For
+	// appended by the proxies in front of the service, the first of them
+	// being the client address logged as remoteIp. The entries before are
+	// sent by the client and not trusted. Defaults to 2, for the client and
+	// load balancer addresses appended by the Google Application Load
+	// Balancers; use 1 for Cloud Run without a load balancer, or a negative
+	// value to ignore X-Forwarded-For.
+	TrustedHops int
+}
+
+const defaultTrustedHops = 2
+
+func (o *MiddlewareOptions) trustedHops() int {
+	if o == nil || o.TrustedHops == 0 {
+		return defaultTrustedHops
+	}
+	return o.TrustedHops
+}
+
+// Middleware returns HTTP server middleware that:
+//
+//   - puts a request-scoped Logger into the request context (see
+//     ContextWithLogger and LoggerFromContext),
+//   - recovers panics, logs them and responds with 500 if nothing was
+//     written yet,
+//   - emits one entry per request with the structured httpRequest object
+//     Cloud Logging understands, at a level picked from the status code,
+//     and the db_queries and db_time_ms fields from QueryStats.
+//
+// Wrap it inside the tracing middleware (e.g. otelhttp.NewHandler) so the
+// entries are correlated with the request trace.
+func Middleware(
+	logger *Logger,
+	options *MiddlewareOptions,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			log := logger.With(
+				String("request_method", req.Method),
+				String("request_path", req.URL.Path),
+			)
+			ctx, stats := contextWithQueryStats(ContextWithLogger(req.Context(), log))
+			req = req.WithContext(ctx)
+
+			rw := &responseRecorder{ResponseWriter: w}
+			startTime := time.Now()
+
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					if recovered == http.ErrAbortHandler {
+						panic(recovered)
+					}
+					log.ErrorContext(
+						ctx,
+						"panic while serving request",
+						Error(panicError(recovered)),
+					)
+					if !rw.wroteHeader {
+						http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					}
+				}
+
+				if options != nil && options.Skip != nil && options.Skip(req) {
+					return
+				}
+
+				status := rw.status
+				if !rw.wroteHeader {
+					status = http.StatusOK
+				}
+				fields := []slog.Attr{
+					Label("log_type", logTypeValueIncomingRequest),
+					httpRequestAttr(req, status, rw.size, time.Since(startTime), options.trustedHops()),
+				}
+				log.LogAttrs(ctx, levelForStatus(status), "served request", appendQueryStats(fields, stats)...)
+			}()
+
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// levelForStatus maps an HTTP status code to the level of its entry: ERROR
+// for 5xx, WARNING for 4xx and INFO otherwise.
+func levelForStatus(status int) slog.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return ErrorLevel
+	case status >= http.StatusBadRequest:
+		return WarnLevel
+	}
+	return InfoLevel
+}
+
+// httpRequestAttr builds the httpRequest object of a Cloud Logging entry.
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+func httpRequestAttr(
+	req *http.Request,
+	status int,
+	responseSize int64,
+	latency time.Duration,
+	trustedHops int,
+) slog.Attr {
+	attrs := []any{
+		String("requestMethod", req.Method),
+		String("requestUrl", requestURL(req)),
+		Int("status", status),
+		String("responseSize", strconv.FormatInt(responseSize, 10)),
+		String("latency", strconv.FormatFloat(latency.Seconds(), 'f', -1, 64)+"s"),
+		String("protocol", req.Proto),
+	}
+	if req.ContentLength > 0 {
+		attrs = append(attrs, String("requestSize", strconv.FormatInt(req.ContentLength, 10)))
+	}
+	if userAgent := req.UserAgent(); userAgent != "" {
+		attrs = append(attrs, String("userAgent", userAgent))
+	}
+	if remoteIP := remoteIP(req, trustedHops); remoteIP != "" {
+		attrs = append(attrs, String("remoteIp", remoteIP))
+	}
+	if referer := req.Referer(); referer != "" {
+		attrs = append(attrs, String("referer", referer))
+	}
+	return slog.Group(logFieldHTTPRequest, attrs...)
+}
+
+func requestURL(req *http.Request) string {
+	if req.URL.IsAbs() {
+		return req.URL.String()
+	}
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := req.Header.Get("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+	return scheme + "://" + req.Host + req.URL.RequestURI()
+}
+
+// remoteIP returns the client address: the entry of X-Forwarded-For
+// trustedHops from its end, as the entries before it can be set by the
+// client, or the address of the peer if the header is missing or shorter.
+func remoteIP(req *http.Request, trustedHops int) string {
+	if forwarded := req.Header.Values("X-Forwarded-For"); len(forwarded) != 0 && trustedHops > 0 {
+		entries := strings.Split(strings.Join(forwarded, ","), ",")
+		if len(entries) >= trustedHops {
+			return strings.TrimSpace(entries[len(entries)-trustedHops])
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func panicError(recovered any) error {
+	if err, ok := recovered.(error); ok {
+		return fmt.Errorf("panic: %w", err)
+	}
+	return fmt.Errorf("panic: %v", recovered)
+}
+
+// responseRecorder captures the status code and the number of bytes written
+// by the wrapped handler.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher for streaming handlers.
+func (rw *responseRecorder) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying ResponseWriter.
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
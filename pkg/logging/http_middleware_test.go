@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	mh := &mockHandler{}
+	logger := &Logger{Logger: slog.New(mh)}
+
+	handler := Middleware(logger, nil)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if LoggerFromContext(req.Context()) == nil {
+			t.Errorf("expected a request-scoped logger in the context")
+		}
+		QueryStatsFromContext(req.Context()).add("fingerprint", 3*time.Millisecond)
+		if req.URL.Path == "/panic" {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if got := mh.LastMessage(); got != "served request" {
+		t.Fatalf("expected request entry, got %q", got)
+	}
+	if level := mh.records[len(mh.records)-1].Level; level != WarnLevel {
+		t.Errorf("expected WARNING for a 404, got %v", level)
+	}
+	var queries int64
+	mh.records[len(mh.records)-1].Attrs(func(a slog.Attr) bool {
+		if a.Key == "db_queries" {
+			queries = a.Value.Int64()
+		}
+		return true
+	})
+	if queries != 1 {
+		t.Errorf("expected db_queries 1, got %d", queries)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 after a panic, got %d", rec.Code)
+	}
+
+	last := mh.records[len(mh.records)-1]
+	if last.Level != ErrorLevel {
+		t.Errorf("expected ERROR for a 500, got %v", last.Level)
+	}
+	var status int64
+	last.Attrs(func(a slog.Attr) bool {
+		if a.Key == "httpRequest" {
+			for _, field := range a.Value.Group() {
+				if field.Key == "status" {
+					status = field.Value.Int64()
+				}
+			}
+		}
+		return true
+	})
+	if status != http.StatusInternalServerError {
+		t.Errorf("expected httpRequest.status 500, got %d", status)
+	}
+	if got := mh.records[len(mh.records)-2].Message; got != "panic while serving request" {
+		t.Errorf("expected the panic to be logged, got %q", got)
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	tests := []struct {
+		name         string
+		forwardedFor []string
+		trustedHops  int
+		wantRemoteIP string
+	}{
+		{"behind load balancer", []string{"203.0.113.7, 198.51.100.1"}, 2, "203.0.113.7"},
+		{"spoofed entry", []string{"10.0.0.1, 203.0.113.7, 198.51.100.1"}, 2, "203.0.113.7"},
+		{"repeated header", []string{"10.0.0.1", "203.0.113.7,198.51.100.1"}, 2, "203.0.113.7"},
+		{"cloud run", []string{"10.0.0.1, 203.0.113.7"}, 1, "203.0.113.7"},
+		{"too few entries", []string{"203.0.113.7"}, 2, "192.0.2.1"},
+		{"header ignored", []string{"203.0.113.7, 198.51.100.1"}, -1, "192.0.2.1"},
+		{"no header", nil, 2, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if got := remoteIP(req, tt.trustedHops); got != tt.wantRemoteIP {
+				t.Errorf("expected %q, got %q", tt.wantRemoteIP, got)
+			}
+		})
+	}
+}
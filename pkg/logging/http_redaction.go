@@ -0,0 +1,226 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// HTTPRedaction lists the headers and query parameters whose values must
+// never reach the log output. LoggingTransport applies it to the url field
+// and to the dumps of the package level DumpRequest and DumpResponse, its
+// DumpRequest and DumpResponse methods apply it to dumps made elsewhere.
+//
+//	redaction := logging.DefaultHTTPRedaction()
+//	redaction.Headers = append(redaction.Headers, "X-Partner-Signature")
+//	options := &logging.LoggingOptions{
+//		Redaction:        redaction,
+//		DumpRequestFunc:  logging.DumpRequest,
+//		DumpResponseFunc: logging.DumpResponse,
+//	}
+type HTTPRedaction struct {
+	// Headers lists the headers whose values are replaced in the dumps.
+	// Matching is case-insensitive.
+	Headers []string
+	// QueryParams lists the query parameters whose values are replaced in
+	// the url field, the request dump, including its form body, and captured
+	// form bodies. Matching is case-insensitive.
+	QueryParams []string
+	// Replacement is the text substituted for redacted values. Defaults to
+	// "[REDACTED]".
+	Replacement string
+}
+
+// DefaultHTTPRedaction returns a redaction covering the usual credential
+// headers and the query parameters carrying API keys, OAuth tokens and
+// signatures. Callers are free to extend the returned redaction. The
+// password of a URL is always redacted.
+func DefaultHTTPRedaction() *HTTPRedaction {
+	return &HTTPRedaction{
+		Headers: []string{
+			"Authorization",
+			"Proxy-Authorization",
+			"Cookie",
+			"Set-Cookie",
+			"X-Api-Key",
+			"Api-Key",
+			"X-Auth-Token",
+			"X-Goog-Api-Key",
+			"X-Amz-Security-Token",
+		},
+		QueryParams: []string{
+			"access_token",
+			"refresh_token",
+			"id_token",
+			"token",
+			"code",
+			"client_secret",
+			"password",
+			"api_key",
+			"apikey",
+			"key",
+			"signature",
+			"sig",
+			"x-goog-signature",
+			"x-goog-credential",
+			"x-amz-signature",
+			"x-amz-credential",
+			"x-amz-security-token",
+		},
+	}
+}
+
+var defaultHTTPRedaction = DefaultHTTPRedaction()
+
+type httpRedactionContextKey struct{}
+
+// contextWithHTTPRedaction hands the redaction of the LoggingOptions over to
+// the package level DumpRequest and DumpResponse.
+func contextWithHTTPRedaction(ctx context.Context, redaction *HTTPRedaction) context.Context {
+	return context.WithValue(ctx, httpRedactionContextKey{}, redaction)
+}
+
+// httpRedactionFromRequest returns the redaction put into the context of the
+// request by LoggingTransport, or DefaultHTTPRedaction.
+func httpRedactionFromRequest(req *http.Request) *HTTPRedaction {
+	if req != nil {
+		if redaction, ok := req.Context().Value(httpRedactionContextKey{}).(*HTTPRedaction); ok {
+			return redaction
+		}
+	}
+	return defaultHTTPRedaction
+}
+
+// DumpRequest is the package level DumpRequest with the headers and query
+// parameters of the redaction replaced, in the URL and in a form body.
+func (r *HTTPRedaction) DumpRequest(loggerFields []any, req *http.Request) []any {
+	if req == nil {
+		return dumpRequest(loggerFields, nil)
+	}
+	redacted := req.Clone(req.Context())
+	redacted.Header = r.header(req.Header)
+	redacted.URL = r.url(req.URL)
+
+	if !isFormRequest(req) {
+		loggerFields = dumpRequest(loggerFields, redacted)
+		// The dump replaces the body it reads with a copy.
+		req.Body = redacted.Body
+		return loggerFields
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	// The request still has to send the original body, and fail as it would
+	// have if reading it failed.
+	req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), &errorReader{err: err}))
+	if err != nil {
+		return append(
+			loggerFields,
+			String(logFieldRequestDumpError, fmt.Sprintf("Error dumping request: %v", err)),
+		)
+	}
+	form := r.query(string(body))
+	redacted.Body = io.NopCloser(strings.NewReader(form))
+	redacted.ContentLength = int64(len(form))
+	return dumpRequest(loggerFields, redacted)
+}
+
+func isFormRequest(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded"
+}
+
+// errorReader returns err, or io.EOF if nil.
+type errorReader struct {
+	err error
+}
+
+func (r *errorReader) Read([]byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
+
+// DumpResponse is the package level DumpResponse with the headers of the
+// redaction replaced.
+func (r *HTTPRedaction) DumpResponse(loggerFields []any, resp *http.Response) []any {
+	if resp == nil {
+		return dumpResponse(loggerFields, nil)
+	}
+	redacted := *resp
+	redacted.Header = r.header(resp.Header)
+	loggerFields = dumpResponse(loggerFields, &redacted)
+	resp.Body = redacted.Body
+	return loggerFields
+}
+
+// URL returns the string form of u with the values of the query parameters
+// of the redaction and its password replaced.
+func (r *HTTPRedaction) URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return r.url(u).String()
+}
+
+func (r *HTTPRedaction) url(u *url.URL) *url.URL {
+	if u == nil {
+		return nil
+	}
+	redacted := *u
+	if _, ok := u.User.Password(); ok {
+		redacted.User = url.UserPassword(u.User.Username(), r.replacement())
+	}
+	if u.RawQuery != "" {
+		redacted.RawQuery = r.query(u.RawQuery)
+	}
+	return &redacted
+}
+
+// query redacts a URL encoded query or form body, rewritten by hand to keep
+// the order and encoding of the other parameters.
+func (r *HTTPRedaction) query(query string) string {
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if name, err := url.QueryUnescape(key); err == nil && r.matches(r.QueryParams, name) {
+			params[i] = key + "=" + r.replacement()
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func (r *HTTPRedaction) header(header http.Header) http.Header {
+	redacted := header.Clone()
+	for name, values := range redacted {
+		if r.matches(r.Headers, name) {
+			for i := range values {
+				values[i] = r.replacement()
+			}
+		}
+	}
+	return redacted
+}
+
+func (r *HTTPRedaction) matches(names []string, name string) bool {
+	return slices.ContainsFunc(names, func(n string) bool {
+		return strings.EqualFold(n, name)
+	})
+}
+
+func (r *HTTPRedaction) replacement() string {
+	if r.Replacement == "" {
+		return defaultRedactionReplacement
+	}
+	return r.Replacement
+}
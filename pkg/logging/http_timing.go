@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+const logFieldTiming = "timing"
+
+// callTiming collects the connection timings of a call through
+// httptrace. Its hooks may be called from other goroutines, e.g. for the
+// parallel connection attempts to the addresses of a dual-stack host.
+type callTiming struct {
+	mu sync.Mutex
+
+	start        time.Time
+	getConn      time.Time
+	gotConn      time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+
+	reused   bool
+	wasIdle  bool
+	idleTime time.Duration
+}
+
+func newCallTiming() *callTiming {
+	return &callTiming{start: time.Now()}
+}
+
+func (t *callTiming) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.set(&t.getConn)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn = time.Now()
+			t.reused, t.wasIdle, t.idleTime = info.Reused, info.WasIdle, info.IdleTime
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.set(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.set(&t.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(string, string, error) {
+			t.set(&t.connectDone)
+		},
+		TLSHandshakeStart: func() {
+			t.set(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.set(&t.tlsDone)
+		},
+		GotFirstResponseByte: func() {
+			t.set(&t.firstByte)
+		},
+	}
+}
+
+func (t *callTiming) set(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*field = time.Now()
+}
+
+// attr returns the timing group: the duration of the phases the call went
+// through in milliseconds, like duration_ms, and whether it reused a pooled
+// connection. get_conn_ms is the time spent obtaining a connection, including
+// DNS, connect and TLS for a new one, ttfb_ms the time from the start of the
+// call to the first response byte.
+func (t *callTiming) attr() slog.Attr {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	attrs := []any{slog.Bool("reused", t.reused)}
+	if t.wasIdle {
+		attrs = append(attrs, durationMs("idle_ms", t.idleTime))
+	}
+	for _, phase := range []struct {
+		key        string
+		start, end time.Time
+	}{
+		{"dns_ms", t.dnsStart, t.dnsDone},
+		{"connect_ms", t.connectStart, t.connectDone},
+		{"tls_ms", t.tlsStart, t.tlsDone},
+		{"get_conn_ms", t.getConn, t.gotConn},
+		{"ttfb_ms", t.start, t.firstByte},
+	} {
+		if !phase.start.IsZero() && !phase.end.IsZero() {
+			attrs = append(attrs, durationMs(phase.key, phase.end.Sub(phase.start)))
+		}
+	}
+	return slog.Group(logFieldTiming, attrs...)
+}
+
+// durationMs keeps the fractions of a millisecond, as most phases on a warm
+// connection are shorter.
+func durationMs(key string, d time.Duration) slog.Attr {
+	return Float64(key, float64(d)/float64(time.Millisecond))
+}
@@ -1,8 +1,12 @@
 package logging
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/http/httptrace"
 	"net/http/httputil"
 	"time"
 )
@@ -14,11 +18,53 @@ const (
 	logFieldResponseDump      = "response"
 	logFieldRequestDumpError  = "request_dump_error"
 	logFieldResponseDumpError = "response_dump_error"
+	logFieldSlow              = "slow"
 )
 
 type LoggingOptions struct {
 	DumpRequestFunc  func(args []any, req *http.Request) []any
 	DumpResponseFunc func(args []any, resp *http.Response) []any
+
+	// SuccessLevel is the level of the entry for a 1xx, 2xx or 3xx response.
+	// Defaults to INFO.
+	SuccessLevel slog.Leveler
+	// ClientErrorLevel is the level of the entry for a 4xx response. Defaults
+	// to WARNING.
+	ClientErrorLevel slog.Leveler
+	// ServerErrorLevel is the level of the entry for a 5xx response. Defaults
+	// to ERROR.
+	ServerErrorLevel slog.Leveler
+	// CanceledLevel is the level of the entry for a call failing because its
+	// context was canceled or its deadline exceeded. Other failures are
+	// logged at ERROR. Defaults to WARNING.
+	CanceledLevel slog.Leveler
+
+	// Redaction is applied to the url field, to captured bodies and to the
+	// dumps of the package level DumpRequest and DumpResponse. Defaults to
+	// DefaultHTTPRedaction().
+	Redaction *HTTPRedaction
+
+	// SlowThreshold tags calls running longer with the slow field and logs
+	// them at WARNING at least. Zero disables it.
+	SlowThreshold time.Duration
+
+	// BodyCaptureLimit, if positive, adds up to that many bytes of the
+	// request and response bodies to the entry, without consuming them: the
+	// bodies are teed as they are read, and the entry of a response is only
+	// emitted once the caller has read its body to the end or closed it.
+	// JSON bodies are logged as structured JSON, text bodies as strings,
+	// binary and multipart bodies are summarized by content type and size.
+	// Unlike the dumps, large downloads and streaming responses are safe.
+	BodyCaptureLimit int
+
+	// Timing adds the timing group to the entry, with the time spent in DNS,
+	// connect, TLS handshake and obtaining a connection from the pool, the
+	// time to first byte and whether the connection was reused.
+	Timing bool
+
+	// Rules override these options for the calls they match, the first
+	// matching rule applies. See LoggingRule.
+	Rules []LoggingRule
 }
 
 type LoggingTransport struct {
@@ -40,20 +86,50 @@ func NewLoggingTransport(
 }
 
 func (lt *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rule := lt.o.rule(req)
+	if rule != nil && rule.Disabled {
+		return lt.rt.RoundTrip(req)
+	}
+	options := lt.o.withRule(rule)
+
 	ctx := req.Context()
+	if options != nil && options.Redaction != nil {
+		ctx = contextWithHTTPRedaction(ctx, options.Redaction)
+		req = req.WithContext(ctx)
+	}
 	log := LoggerFromContext(ctx)
 	if log == nil {
 		log = lt.l
 	}
 	var loggerFields []any
+	if rule != nil && rule.PeerService != "" {
+		loggerFields = append(
+			loggerFields,
+			Labels("log_type", logTypeValueExternalRequest, logFieldPeerService, rule.PeerService),
+		)
+	} else {
+		loggerFields = append(loggerFields, Label("log_type", logTypeValueExternalRequest))
+	}
 	loggerFields = append(
 		loggerFields,
-		Label("log_type", logTypeValueExternalRequest),
-		String("url", req.URL.String()),
+		String("url", options.redaction().URL(req.URL)),
 	)
 
-	if lt.o != nil && lt.o.DumpRequestFunc != nil {
-		loggerFields = lt.o.DumpRequestFunc(loggerFields, req)
+	if options != nil && options.DumpRequestFunc != nil {
+		loggerFields = options.DumpRequestFunc(loggerFields, req)
+	}
+
+	// RoundTrip must not modify the request of the caller, hence the copies.
+	var requestBody *bodyCapture
+	if limit := options.bodyCaptureLimit(); limit > 0 && req.Body != nil && req.Body != http.NoBody {
+		requestBody = &bodyCapture{limit: limit}
+		req = req.WithContext(ctx)
+		req.Body = &captureReadCloser{ReadCloser: req.Body, capture: requestBody}
+	}
+	var timing *callTiming
+	if options != nil && options.Timing {
+		timing = newCallTiming()
+		req = req.WithContext(httptrace.WithClientTrace(ctx, timing.clientTrace()))
 	}
 
 	startTime := time.Now()
@@ -64,25 +140,63 @@ func (lt *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 		Duration("duration", duration),
 		Int64("duration_ms", duration.Milliseconds()),
 	)
+	slow := options != nil && options.SlowThreshold != 0 && duration > options.SlowThreshold
+	if slow {
+		loggerFields = append(loggerFields, slog.Bool(logFieldSlow, true))
+	}
+	if timing != nil {
+		loggerFields = append(loggerFields, timing.attr())
+	}
+	if requestBody != nil {
+		loggerFields = append(loggerFields, requestBody.fields(logFieldRequestBody, req.Header.Get("Content-Type"), options.redaction())...)
+	}
 	if err != nil {
 		loggerFields = append(loggerFields, Error(err))
-		log.ErrorContext(
+		log.Log(
 			ctx,
+			slowLevel(options.errorLevel(err), slow),
 			"call to external service FAILED",
 			loggerFields...,
 		)
 		return nil, err
 	}
-	if lt.o != nil && lt.o.DumpResponseFunc != nil {
-		loggerFields = lt.o.DumpResponseFunc(loggerFields, resp)
+	if !slow && resp.StatusCode < http.StatusBadRequest && rule.sampledOut() {
+		return resp, nil
+	}
+	if options != nil && options.DumpResponseFunc != nil {
+		loggerFields = options.DumpResponseFunc(loggerFields, resp)
 	}
 	loggerFields = append(
 		loggerFields,
 		Int("status", resp.StatusCode),
 	)
 
-	log.InfoContext(
+	level := slowLevel(options.statusLevel(resp.StatusCode), slow)
+	// The body of a protocol switch is the connection itself. An empty body,
+	// e.g. of a HEAD request or a 204, may never be read nor closed, so its
+	// entry is not delayed.
+	if limit := options.bodyCaptureLimit(); limit > 0 && resp.Body != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+		responseBody := &bodyCapture{limit: limit}
+		contentType := resp.Header.Get("Content-Type")
+		if resp.Body == http.NoBody || resp.ContentLength == 0 {
+			loggerFields = append(loggerFields, responseBody.fields(logFieldResponseBody, contentType, options.redaction())...)
+			log.Log(ctx, level, "called external service", loggerFields...)
+			return resp, nil
+		}
+		resp.Body = &captureReadCloser{
+			ReadCloser: resp.Body,
+			capture:    responseBody,
+			done: func() {
+				loggerFields = append(loggerFields, responseBody.fields(logFieldResponseBody, contentType, options.redaction())...)
+				log.Log(ctx, level, "called external service", loggerFields...)
+			},
+		}
+		return resp, nil
+	}
+
+	log.Log(
 		ctx,
+		level,
 		"called external service",
 		loggerFields...,
 	)
@@ -90,10 +204,58 @@ func (lt *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	return resp, nil
 }
 
+func (o *LoggingOptions) statusLevel(status int) slog.Level {
+	if o == nil {
+		return levelForStatus(status)
+	}
+	switch {
+	case status >= http.StatusInternalServerError:
+		return levelOrDefault(o.ServerErrorLevel, ErrorLevel)
+	case status >= http.StatusBadRequest:
+		return levelOrDefault(o.ClientErrorLevel, WarnLevel)
+	}
+	return levelOrDefault(o.SuccessLevel, InfoLevel)
+}
+
+func (o *LoggingOptions) errorLevel(err error) slog.Level {
+	if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return ErrorLevel
+	}
+	if o == nil {
+		return WarnLevel
+	}
+	return levelOrDefault(o.CanceledLevel, WarnLevel)
+}
+
+func (o *LoggingOptions) bodyCaptureLimit() int {
+	if o == nil {
+		return 0
+	}
+	return o.BodyCaptureLimit
+}
+
+func (o *LoggingOptions) redaction() *HTTPRedaction {
+	if o == nil || o.Redaction == nil {
+		return defaultHTTPRedaction
+	}
+	return o.Redaction
+}
+
+// slowLevel raises the level of a slow call to WARNING.
+func slowLevel(level slog.Level, slow bool) slog.Level {
+	if slow && level < WarnLevel {
+		return WarnLevel
+	}
+	return level
+}
+
 // DumpRequest appends a string representation of an HTTP request to the provided loggerFields slice.
 // It includes both headers and body in the dump. If the request is nil or dumping fails, an error message
 // is appended instead. The function returns the updated loggerFields slice.
 //
+// The headers and query parameters of the Redaction of the LoggingOptions, DefaultHTTPRedaction by default,
+// are redacted, see HTTPRedaction.DumpRequest.
+//
 // Parameters:
 //   - loggerFields: a slice of fields to which the request dump or error will be appended.
 //   - req: the HTTP request to be dumped.
@@ -103,6 +265,13 @@ func (lt *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 func DumpRequest(
 	loggerFields []any,
 	req *http.Request,
+) []any {
+	return httpRedactionFromRequest(req).DumpRequest(loggerFields, req)
+}
+
+func dumpRequest(
+	loggerFields []any,
+	req *http.Request,
 ) []any {
 	if req == nil {
 		loggerFields = append(
@@ -134,6 +303,9 @@ func DumpRequest(
 // It includes both headers and body in the dump. If the response is nil or dumping fails, an error message
 // is appended instead. The function returns the updated loggerFields slice.
 //
+// The headers of the Redaction of the LoggingOptions, DefaultHTTPRedaction by default, are redacted, see
+// HTTPRedaction.DumpResponse.
+//
 // Parameters:
 //   - loggerFields: a slice of fields to which the response dump or error will be appended.
 //   - resp: the HTTP response to be dumped.
@@ -141,6 +313,14 @@ func DumpRequest(
 // Returns:
 //   - The updated loggerFields slice with the response dump or an error message.
 func DumpResponse(loggerFields []any, resp *http.Response) []any {
+	var req *http.Request
+	if resp != nil {
+		req = resp.Request
+	}
+	return httpRedactionFromRequest(req).DumpResponse(loggerFields, resp)
+}
+
+func dumpResponse(loggerFields []any, resp *http.Response) []any {
 	if resp == nil {
 		loggerFields = append(
 			loggerFields,
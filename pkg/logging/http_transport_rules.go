@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strings"
+)
+
+const logFieldPeerService = "peer_service"
+
+// LoggingRule overrides LoggingOptions for the calls it matches, e.g. to dump
+// the bodies of one partner API or to skip the calls to the metadata server.
+// Empty match fields match any call.
+//
+//	&logging.LoggingOptions{
+//		Rules: []logging.LoggingRule{
+//			{Host: "metadata.google.internal", Disabled: true},
+//			{Host: "*.partner.example.com", PathPrefix: "/v2/", PeerService: "partner", BodyCaptureLimit: 4096},
+//			{PathPrefix: "/healthz", Method: http.MethodGet, SuccessSampleRate: 0.01},
+//			{Host: "auth.example.com", NoDumps: true, NoBodyCapture: true},
+//		},
+//	}
+type LoggingRule struct {
+	// Host matches the host of the URL, without port, case-insensitively. A
+	// leading "*." matches any subdomain.
+	Host string
+	// PathPrefix matches the beginning of the path of the URL.
+	PathPrefix string
+	// Method matches the method of the request, case-insensitively.
+	Method string
+
+	// Disabled skips the entries of the matching calls altogether.
+	Disabled bool
+	// DumpRequestFunc, DumpResponseFunc and BodyCaptureLimit override the
+	// ones of LoggingOptions when set.
+	DumpRequestFunc  func(args []any, req *http.Request) []any
+	DumpResponseFunc func(args []any, resp *http.Response) []any
+	BodyCaptureLimit int
+	// NoDumps and NoBodyCapture turn off the dumps and the body capture the
+	// LoggingOptions turn on, e.g. for calls exchanging credentials. They take
+	// precedence over the overrides above.
+	NoDumps       bool
+	NoBodyCapture bool
+	// SuccessSampleRate, if between 0 and 1, is the fraction of successful
+	// calls logged. Failed, 4xx, 5xx and slow calls are always logged. Zero
+	// logs every call.
+	SuccessSampleRate float64
+	// PeerService names the called service, added as the peer_service label.
+	PeerService string
+}
+
+func (r *LoggingRule) matches(req *http.Request) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+	if r.PathPrefix != "" && !strings.HasPrefix(req.URL.Path, r.PathPrefix) {
+		return false
+	}
+	if r.Host == "" {
+		return true
+	}
+	host := req.URL.Hostname()
+	if suffix, ok := strings.CutPrefix(r.Host, "*"); ok {
+		return len(host) > len(suffix) && strings.HasSuffix(strings.ToLower(host), strings.ToLower(suffix))
+	}
+	return strings.EqualFold(r.Host, host)
+}
+
+// sampledOut reports whether a successful call is left out by sampling.
+func (r *LoggingRule) sampledOut() bool {
+	return r != nil && r.SuccessSampleRate > 0 && r.SuccessSampleRate < 1 && rand.Float64() >= r.SuccessSampleRate
+}
+
+// rule returns the first rule matching the request, or nil.
+func (o *LoggingOptions) rule(req *http.Request) *LoggingRule {
+	if o == nil {
+		return nil
+	}
+	for i := range o.Rules {
+		if o.Rules[i].matches(req) {
+			return &o.Rules[i]
+		}
+	}
+	return nil
+}
+
+// withRule returns the options with the overrides of the rule applied.
+func (o *LoggingOptions) withRule(rule *LoggingRule) *LoggingOptions {
+	if rule == nil {
+		return o
+	}
+	var options LoggingOptions
+	if o != nil {
+		options = *o
+	}
+	if rule.DumpRequestFunc != nil {
+		options.DumpRequestFunc = rule.DumpRequestFunc
+	}
+	if rule.DumpResponseFunc != nil {
+		options.DumpResponseFunc = rule.DumpResponseFunc
+	}
+	if rule.BodyCaptureLimit != 0 {
+		options.BodyCaptureLimit = rule.BodyCaptureLimit
+	}
+	if rule.NoDumps {
+		options.DumpRequestFunc = nil
+		options.DumpResponseFunc = nil
+	}
+	if rule.NoBodyCapture {
+		options.BodyCaptureLimit = 0
+	}
+	return &options
+}
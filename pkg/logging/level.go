@@ -0,0 +1,361 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const logFieldLoggerName = "logger"
+
+var errLevelNotAdjustable = errors.New("logger was not created by NewLogger, its level cannot be adjusted")
+
+// levelControl holds the minimum levels shared by every Logger derived from
+// the same NewLogger call, so they can be changed at runtime. The root level
+// is backed by a slog.LevelVar; named loggers may override it per dotted
+// name prefix, e.g. "gorm" also applies to "gorm.migrations".
+type levelControl struct {
+	root      slog.LevelVar
+	overrides atomic.Pointer[map[string]slog.Level]
+
+	mu         sync.Mutex
+	configured map[string]slog.Level // "" is the root level
+	reverts    map[string]*time.Timer
+	expiresAt  map[string]time.Time
+}
+
+func newLevelControl(root slog.Level, named map[string]slog.Level) *levelControl {
+	c := &levelControl{
+		configured: map[string]slog.Level{"": root},
+		reverts:    make(map[string]*time.Timer),
+		expiresAt:  make(map[string]time.Time),
+	}
+	c.root.Set(root)
+
+	overrides := make(map[string]slog.Level, len(named))
+	for name, level := range named {
+		c.configured[name] = level
+		overrides[name] = level
+	}
+	c.overrides.Store(&overrides)
+	return c
+}
+
+// levelFor returns the effective level of the named logger: the override of
+// the longest matching dotted prefix, or the root level.
+func (c *levelControl) levelFor(name string) slog.Level {
+	overrides := *c.overrides.Load()
+	for len(overrides) != 0 && name != "" {
+		if level, ok := overrides[name]; ok {
+			return level
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return c.root.Level()
+}
+
+// set changes the level of the named logger ("" for the root logger). A
+// positive expiry reverts the change to the configured level once it has
+// elapsed.
+func (c *levelControl) set(name string, level slog.Level, expiry time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(name, level, expiry)
+}
+
+func (c *levelControl) setLocked(name string, level slog.Level, expiry time.Duration) {
+	if timer, ok := c.reverts[name]; ok {
+		timer.Stop()
+		delete(c.reverts, name)
+	}
+	delete(c.expiresAt, name)
+
+	if name == "" {
+		c.root.Set(level)
+	} else {
+		overrides := maps.Clone(*c.overrides.Load())
+		overrides[name] = level
+		c.overrides.Store(&overrides)
+	}
+
+	if expiry > 0 {
+		c.expiresAt[name] = time.Now().Add(expiry)
+		c.reverts[name] = time.AfterFunc(expiry, func() { c.reset(name) })
+	}
+}
+
+// reset restores the level configured in LoggerConfig for the named logger,
+// or removes its override if none was configured.
+func (c *levelControl) reset(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if level, ok := c.configured[name]; ok {
+		c.setLocked(name, level, 0)
+		return
+	}
+
+	if timer, ok := c.reverts[name]; ok {
+		timer.Stop()
+		delete(c.reverts, name)
+	}
+	delete(c.expiresAt, name)
+	overrides := maps.Clone(*c.overrides.Load())
+	delete(overrides, name)
+	c.overrides.Store(&overrides)
+}
+
+func (c *levelControl) state() levelState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.stateLocked("", c.root.Level())
+	overrides := *c.overrides.Load()
+	if len(overrides) != 0 {
+		state.Loggers = make(map[string]levelState, len(overrides))
+		for name, level := range overrides {
+			state.Loggers[name] = c.stateLocked(name, level)
+		}
+	}
+	return state
+}
+
+func (c *levelControl) stateLocked(name string, level slog.Level) levelState {
+	state := levelState{Level: severityName(level)}
+	if configured, ok := c.configured[name]; ok {
+		state.Configured = severityName(configured)
+	}
+	if expiresAt, ok := c.expiresAt[name]; ok {
+		state.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	}
+	return state
+}
+
+// levelHandler gates records on the level of the named logger it belongs to.
+// Named adds the "logger" field to Handler once, before any group the caller
+// opens afterwards; unnamed is the same chain without it, which nested
+// children are derived from.
+type levelHandler struct {
+	slog.Handler
+	unnamed slog.Handler
+	name    string
+	levels  *levelControl
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.levels == nil {
+		return h.Handler.Enabled(ctx, level)
+	}
+	return level >= h.levels.levelFor(h.name)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{
+		Handler: h.Handler.WithAttrs(attrs),
+		unnamed: h.unnamedHandler().WithAttrs(attrs),
+		name:    h.name,
+		levels:  h.levels,
+	}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{
+		Handler: h.Handler.WithGroup(name),
+		unnamed: h.unnamedHandler().WithGroup(name),
+		name:    h.name,
+		levels:  h.levels,
+	}
+}
+
+func (h *levelHandler) unnamedHandler() slog.Handler {
+	if h.unnamed == nil {
+		return h.Handler
+	}
+	return h.unnamed
+}
+
+// minHandlerLevel is used for the handlers below levelHandler, which makes
+// the actual level decision.
+const minHandlerLevel = slog.Level(math.MinInt32)
+
+// Named returns a child logger that adds a "logger" field with its name.
+// Names of nested children are joined with dots, e.g.
+// logger.Named("http").Named("client") is called "http.client". The level of
+// a named logger can be set through LoggerConfig.Levels and changed at
+// runtime with SetLevel or NewLevelHandler.
+func (l *Logger) Named(name string) *Logger {
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+
+	handler := l.Handler()
+	if lh, ok := handler.(*levelHandler); ok {
+		handler = lh.unnamedHandler()
+	}
+
+	named := l.withSlogLogger(slog.New(&levelHandler{
+		Handler: handler.WithAttrs([]slog.Attr{slog.String(logFieldLoggerName, name)}),
+		unnamed: handler,
+		name:    name,
+		levels:  l.levels,
+	}))
+	named.name = name
+	return named
+}
+
+// Name returns the dotted name given to the logger by Named.
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// Level returns the current minimum level of the logger, taking overrides
+// for its name into account.
+func (l *Logger) Level() slog.Level {
+	if l.levels == nil {
+		return InfoLevel
+	}
+	return l.levels.levelFor(l.name)
+}
+
+// SetLevel changes the minimum level of the logger and of every logger
+// derived from the same NewLogger call that shares its name. On the root
+// logger it changes the default for all loggers without an override of
+// their own. If expiry is positive, the level reverts to the configured one
+// once it has elapsed.
+//
+// Parameters:
+//
+//	level - the new minimum level
+//	expiry - how long the change lasts, or 0 to keep it until changed again
+func (l *Logger) SetLevel(level slog.Level, expiry time.Duration) error {
+	if l.levels == nil {
+		return errLevelNotAdjustable
+	}
+	l.levels.set(l.name, level, expiry)
+	return nil
+}
+
+// ResetLevel restores the minimum level configured in LoggerConfig for the
+// logger's name.
+func (l *Logger) ResetLevel() error {
+	if l.levels == nil {
+		return errLevelNotAdjustable
+	}
+	l.levels.reset(l.name)
+	return nil
+}
+
+type levelState struct {
+	Level      string                `json:"level"`
+	Configured string                `json:"configured,omitempty"`
+	ExpiresAt  string                `json:"expires_at,omitempty"`
+	Loggers    map[string]levelState `json:"loggers,omitempty"`
+}
+
+type levelRequest struct {
+	Level  string `json:"level"`
+	Expiry string `json:"expiry,omitempty"`
+}
+
+// NewLevelHandler returns an http.Handler to read and change the levels of
+// the logger at runtime. Mount it on an internal/admin route only.
+//
+//	GET    returns the current levels, e.g. {"level":"DEBUG","configured":"INFO","expires_at":"...","loggers":{...}}
+//	PUT    changes a level from a body like {"level":"DEBUG","expiry":"10m"}
+//	DELETE reverts a level to the configured one
+//
+// PUT and DELETE apply to the root level unless a named logger is selected
+// with the "logger" query parameter, e.g. PUT /loglevel?logger=gorm.
+// Levels are accepted both as Cloud Logging severities ("WARNING") and as
+// slog level names ("WARN", "DEBUG-4").
+func NewLevelHandler(logger *Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if logger.levels == nil {
+			http.Error(w, errLevelNotAdjustable.Error(), http.StatusNotImplemented)
+			return
+		}
+
+		name := r.URL.Query().Get(logFieldLoggerName)
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var request levelRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+			level, err := ParseLevel(request.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var expiry time.Duration
+			if request.Expiry != "" {
+				if expiry, err = time.ParseDuration(request.Expiry); err != nil {
+					http.Error(w, fmt.Sprintf("invalid expiry: %v", err), http.StatusBadRequest)
+					return
+				}
+			}
+			logger.levels.set(name, level, expiry)
+			logger.InfoContext(
+				r.Context(),
+				"log level changed",
+				String("target_logger", name),
+				String("level", severityName(level)),
+				Duration("expiry", expiry),
+			)
+		case http.MethodDelete:
+			logger.levels.reset(name)
+			logger.InfoContext(
+				r.Context(),
+				"log level reset",
+				String("target_logger", name),
+			)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(logger.levels.state())
+	})
+}
+
+// ParseLevel parses a level given either as a Cloud Logging severity name
+// (DEBUG, INFO, WARNING, ERROR, CRITICAL, EMERGENCY) or in the format
+// accepted by slog.Level.UnmarshalText (e.g. "WARN", "INFO+2").
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "TRACE":
+		return TraceLevel, nil
+	case "WARNING":
+		return WarnLevel, nil
+	case "CRITICAL", "PANIC":
+		return PanicLevel, nil
+	case "EMERGENCY", "FATAL":
+		return FatalLevel, nil
+	case "DPANIC":
+		return DPanicLevel, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid level %q: %w", s, err)
+	}
+	return level, nil
+}
@@ -22,6 +22,11 @@
 // explicit seam they provide. For now most helpers are thin, but the design
 // allows us to layer in policy when needed (e.g. redaction, normalization).
 //
+// Redaction is the first such policy: a RedactionPolicy set through
+// LoggerConfig is enforced by the handler behind every Logger, so values
+// built with Xxx() are masked no matter whether they are passed to a log call,
+// to Logger.With or to ContextWithLoggerFields.
+//
 // Developers new to this codebase should not assume Xxx() does anything
 // magical today. Think of it as a hedge that keeps our logging consistent and
 // adaptable.
@@ -38,6 +43,8 @@ import (
 	"strings"
 	"time"
 
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 
 	"google.golang.org/protobuf/encoding/protojson"
@@ -45,6 +52,9 @@ import (
 )
 
 const (
+	// TraceLevel logs are finer grained than Debug, e.g. the internals of
+	// libraries such as Watermill.
+	TraceLevel = slog.LevelDebug - 4
 	// DebugLevel logs are typically voluminous, and are usually disabled in
 	// production.
 	DebugLevel = slog.LevelDebug
@@ -67,6 +77,12 @@ const (
 
 type Logger struct {
 	*slog.Logger
+
+	// levels is shared by every Logger derived from the same NewLogger call.
+	levels      *levelControl
+	async       *asyncWriter
+	name        string
+	development bool
 }
 
 type LoggerWithContext struct {
@@ -79,11 +95,42 @@ type LoggerConfig struct {
 	ProjectID string
 	// ServiceName specifies the name of the service emitting logs.
 	ServiceName string
-	// MinLevel sets the minimum log level to be recorded.
+	// MinLevel sets the minimum log level to be recorded. It can be changed
+	// at runtime with Logger.SetLevel or the handler from NewLevelHandler.
 	MinLevel slog.Level
+	// Levels overrides MinLevel for loggers created with Logger.Named, keyed
+	// by dotted name prefix, e.g. {"gorm": WarnLevel, "http.client": DebugLevel}.
+	Levels map[string]slog.Level
 
 	// Output specifies where logs should be written. If nil, defaults to os.Stdout.
 	Output io.Writer
+
+	// Redaction, if set, masks sensitive values before they are written,
+	// whichever call path added them. See DefaultRedactionPolicy.
+	Redaction *RedactionPolicy
+	// SpanEvents controls how log entries are recorded as events on the span
+	// found in the context. If nil, the SpanEventsConfig defaults apply.
+	SpanEvents *SpanEventsConfig
+	// ErrorReporting formats entries at ErrorLevel and above as GCP Error
+	// Reporting events (@type, context.reportLocation and a Go formatted
+	// stack_trace) instead of attaching a free-form "stacktrace" field.
+	ErrorReporting bool
+	// Development makes DPanic and DPanicContext panic after logging and
+	// replaces the GCP JSON output with colorized, human-readable console
+	// output. Set the NO_COLOR environment variable to disable colors.
+	Development bool
+	// Async, if set, writes entries from a bounded queue on a background
+	// goroutine. Call Logger.Sync or Logger.Close before the process exits.
+	Async *AsyncConfig
+	// MeterProvider, if set, is used to record OpenTelemetry counters for
+	// the records emitted (per severity and logger name), the bytes written,
+	// the records dropped and the failed writes.
+	MeterProvider metric.MeterProvider
+	// LoggerProvider, if set, additionally receives every entry through an
+	// OpenTelemetry log bridge, e.g. to export it via OTLP. Trace correlation
+	// uses OpenTelemetry's native fields instead of the logging.googleapis.com
+	// keys. Set Output to io.Discard to only export via OpenTelemetry.
+	LoggerProvider otellog.LoggerProvider
 }
 
 type (
@@ -93,7 +140,8 @@ type (
 
 type spanContextLogHandler struct {
 	slog.Handler
-	ProjectID string
+	ProjectID      string
+	ErrorReporting bool
 }
 
 func NewLogger(config *LoggerConfig) *Logger {
@@ -102,22 +150,70 @@ func NewLogger(config *LoggerConfig) *Logger {
 		output = os.Stdout
 	}
 
-	jsonHandler := slog.NewJSONHandler(output, &slog.HandlerOptions{
-		AddSource:   true,
-		ReplaceAttr: replacer,
-		Level:       config.MinLevel,
-	})
+	levels := newLevelControl(config.MinLevel, config.Levels)
+
+	var metrics *logMetrics
+	if config.MeterProvider != nil {
+		metrics = newLogMetrics(config.MeterProvider)
+		output = &meteredWriter{out: output, metrics: metrics}
+	}
+
+	var async *asyncWriter
+	if config.Async != nil {
+		var onDrop func()
+		if metrics != nil {
+			onDrop = func() { metrics.dropped.Add(context.Background(), 1) }
+		}
+		async = newAsyncWriter(output, config.Async, onDrop)
+		output = async
+	}
+
+	var handler slog.Handler
+	if config.Development {
+		handler = newConsoleHandler(output, os.Getenv("NO_COLOR") == "")
+	} else {
+		handler = slog.NewJSONHandler(output, &slog.HandlerOptions{
+			AddSource:   true,
+			ReplaceAttr: replacer,
+			Level:       minHandlerLevel,
+		})
+	}
+	handler = handlerWithSpanEvents(config.SpanEvents, handler)
+	if config.Redaction != nil {
+		handler = handlerWithRedaction(config.Redaction, handler)
+	}
 	instrumentedHandler := handlerWithSpanContext(
 		config.ProjectID,
-		jsonHandler,
+		handler,
 	)
-	log := slog.New(instrumentedHandler)
+	instrumentedHandler.ErrorReporting = config.ErrorReporting
+	handler = instrumentedHandler
+	if config.LoggerProvider != nil {
+		var bridge slog.Handler = newOtelLogHandler(config.LoggerProvider)
+		if config.Redaction != nil {
+			bridge = handlerWithRedaction(config.Redaction, bridge)
+		}
+		handler = &fanoutHandler{handlers: []slog.Handler{
+			handler,
+			&contextFieldsHandler{Handler: bridge},
+		}}
+	}
+	if metrics != nil {
+		handler = &metricsHandler{Handler: handler, metrics: metrics}
+	}
+	log := slog.New(&levelHandler{
+		Handler: handler,
+		levels:  levels,
+	})
 
 	return &Logger{
 		Logger: log.With(slog.Group("serviceContext", String(
 			"service",
 			config.ServiceName,
 		))),
+		levels:      levels,
+		async:       async,
+		development: config.Development,
 	}
 }
 
@@ -147,15 +243,82 @@ func (l *Logger) WithContext(
 	return log
 }
 
-// Deprecated: for backwards compatibility.
+// Sync flushes entries buffered by an asynchronous logger (see
+// LoggerConfig.Async) and returns the first write error since the previous
+// Sync. It is a no-op for synchronous loggers.
 func (l *Logger) Sync() error {
-	return nil
+	if l.async == nil {
+		return nil
+	}
+	return l.async.Sync()
+}
+
+// Close flushes an asynchronous logger and stops its background goroutine.
+// Entries logged afterwards are written synchronously. It is a no-op for
+// synchronous loggers.
+func (l *Logger) Close() error {
+	if l.async == nil {
+		return nil
+	}
+	return l.async.Close()
+}
+
+// Dropped returns the number of entries an asynchronous logger discarded
+// because its queue was full.
+func (l *Logger) Dropped() uint64 {
+	if l.async == nil {
+		return 0
+	}
+	return l.async.Dropped()
 }
 
 func (l *Logger) With(args ...any) *Logger {
 	log := l.Logger.With(args...)
 
-	return &Logger{Logger: log}
+	return l.withSlogLogger(log)
+}
+
+// withSlogLogger returns a copy of l that logs through log, keeping the
+// state shared with the Logger it was derived from.
+func (l *Logger) withSlogLogger(log *slog.Logger) *Logger {
+	derived := *l
+	derived.Logger = log
+	return &derived
+}
+
+// DPanicContext logs at [DPanicLevel] with the given context. In development
+// (see LoggerConfig.Development) it then panics with the given message.
+//
+// Parameters:
+//
+//	ctx - the context for logging
+//	msg - the message to log and, in development, panic with
+//	args - additional arguments for formatting the log message
+func (l *Logger) DPanicContext(
+	ctx context.Context,
+	msg string,
+	args ...any,
+) {
+	l.dpanic(ctx, callerPC(1), msg, args...)
+}
+
+// DPanic logs at [DPanicLevel]. In development (see LoggerConfig.Development)
+// it then panics with the given message.
+//
+// Parameters:
+//
+//	msg - the message to log and, in development, panic with
+//	args - additional arguments for formatting the log message
+func (l *Logger) DPanic(msg string, args ...any) {
+	l.dpanic(context.Background(), callerPC(1), msg, args...)
+}
+
+func (l *Logger) dpanic(ctx context.Context, pc uintptr, msg string, args ...any) {
+	l.logPC(ctx, pc, DPanicLevel, msg, args...)
+	if l.development {
+		_ = l.Sync()
+		panic(msg)
+	}
 }
 
 // PanicContext logs at [PanicLevel] with the given context and then panics with the given message.
@@ -170,8 +333,7 @@ func (l *Logger) PanicContext(
 	msg string,
 	args ...any,
 ) {
-	l.Log(ctx, slog.Level(PanicLevel), msg, args...)
-	panic(msg)
+	l.panic(ctx, callerPC(1), msg, args...)
 }
 
 // Panic logs at [PanicLevel] and then panics with the given message.
@@ -181,7 +343,12 @@ func (l *Logger) PanicContext(
 //	msg - the message to log and panic with
 //	args - additional arguments for formatting the log message
 func (l *Logger) Panic(msg string, args ...any) {
-	l.PanicContext(context.Background(), msg, args...)
+	l.panic(context.Background(), callerPC(1), msg, args...)
+}
+
+func (l *Logger) panic(ctx context.Context, pc uintptr, msg string, args ...any) {
+	l.logPC(ctx, pc, PanicLevel, msg, args...)
+	_ = l.Sync()
 	panic(msg)
 }
 
@@ -197,8 +364,7 @@ func (l *Logger) FatalContext(
 	msg string,
 	args ...any,
 ) {
-	l.Log(ctx, slog.Level(FatalLevel), msg, args...)
-	os.Exit(1)
+	l.fatal(ctx, callerPC(1), msg, args...)
 }
 
 // Fatal logs at [FatalLevel] and then terminates the application.
@@ -208,7 +374,12 @@ func (l *Logger) FatalContext(
 //	msg - the message to log before exiting
 //	args - additional arguments for formatting the log message
 func (l *Logger) Fatal(msg string, args ...any) {
-	l.FatalContext(context.Background(), msg, args...)
+	l.fatal(context.Background(), callerPC(1), msg, args...)
+}
+
+func (l *Logger) fatal(ctx context.Context, pc uintptr, msg string, args ...any) {
+	l.logPC(ctx, pc, FatalLevel, msg, args...)
+	_ = l.Sync()
 	os.Exit(1)
 }
 
@@ -228,42 +399,49 @@ func (lc *LoggerWithContext) Debug(
 	msg string,
 	args ...any,
 ) {
-	lc.l.DebugContext(lc.ctx, msg, args...)
+	lc.l.logPC(lc.ctx, callerPC(1), DebugLevel, msg, args...)
 }
 
 func (lc *LoggerWithContext) Info(
 	msg string,
 	args ...any,
 ) {
-	lc.l.InfoContext(lc.ctx, msg, args...)
+	lc.l.logPC(lc.ctx, callerPC(1), InfoLevel, msg, args...)
 }
 
 func (lc *LoggerWithContext) Warn(
 	msg string,
 	args ...any,
 ) {
-	lc.l.WarnContext(lc.ctx, msg, args...)
+	lc.l.logPC(lc.ctx, callerPC(1), WarnLevel, msg, args...)
 }
 
 func (lc *LoggerWithContext) Error(
 	msg string,
 	args ...any,
 ) {
-	lc.l.ErrorContext(lc.ctx, msg, args...)
+	lc.l.logPC(lc.ctx, callerPC(1), ErrorLevel, msg, args...)
+}
+
+func (lc *LoggerWithContext) DPanic(
+	msg string,
+	args ...any,
+) {
+	lc.l.dpanic(lc.ctx, callerPC(1), msg, args...)
 }
 
 func (lc *LoggerWithContext) Panic(
 	msg string,
 	args ...any,
 ) {
-	lc.l.PanicContext(lc.ctx, msg, args...)
+	lc.l.panic(lc.ctx, callerPC(1), msg, args...)
 }
 
 func (lc *LoggerWithContext) Fatal(
 	msg string,
 	args ...any,
 ) {
-	lc.l.FatalContext(lc.ctx, msg, args...)
+	lc.l.fatal(lc.ctx, callerPC(1), msg, args...)
 }
 
 func handlerWithSpanContext(projectID string, handler slog.Handler) *spanContextLogHandler {
@@ -285,7 +463,9 @@ func (t *spanContextLogHandler) Handle(ctx context.Context, record slog.Record)
 		record.AddAttrs(attrs...)
 	}
 
-	if record.Level >= slog.LevelWarn {
+	if t.ErrorReporting && record.Level >= slog.LevelError {
+		record.AddAttrs(errorReportingAttrs(record, debug.Stack())...)
+	} else if record.Level >= slog.LevelWarn {
 		stack := debug.Stack()
 		record.AddAttrs(
 			slog.String("stacktrace",
@@ -312,15 +492,17 @@ func (t *spanContextLogHandler) Handle(ctx context.Context, record slog.Record)
 
 func (t *spanContextLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	return &spanContextLogHandler{
-		ProjectID: t.ProjectID,
-		Handler:   t.Handler.WithAttrs(attrs),
+		ProjectID:      t.ProjectID,
+		ErrorReporting: t.ErrorReporting,
+		Handler:        t.Handler.WithAttrs(attrs),
 	}
 }
 
 func (t *spanContextLogHandler) WithGroup(name string) slog.Handler {
 	return &spanContextLogHandler{
-		ProjectID: t.ProjectID,
-		Handler:   t.Handler.WithGroup(name),
+		ProjectID:      t.ProjectID,
+		ErrorReporting: t.ErrorReporting,
+		Handler:        t.Handler.WithGroup(name),
 	}
 }
 
@@ -349,24 +531,13 @@ func replacer(groups []string, a slog.Attr) slog.Attr {
 	// Rename attribute keys to match Cloud Logging structured log format
 	switch a.Key {
 	case slog.LevelKey:
-		a.Key = "severity"
-		level := a.Value.Any().(slog.Level)
-		// Map slog.Level string values to Cloud Logging LogSeverity
-		// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
-		switch level {
-		case DebugLevel:
-			a.Value = slog.StringValue("DEBUG")
-		case InfoLevel:
-			a.Value = slog.StringValue("INFO")
-		case WarnLevel:
-			a.Value = slog.StringValue("WARNING")
-		case ErrorLevel, DPanicLevel:
-			a.Value = slog.StringValue("ERROR")
-		case PanicLevel:
-			a.Value = slog.StringValue("CRITICAL")
-		case FatalLevel:
-			a.Value = slog.StringValue("EMERGENCY")
+		level, ok := a.Value.Any().(slog.Level)
+		if !ok {
+			// A user attribute that happens to be called "level".
+			return a
 		}
+		a.Key = "severity"
+		a.Value = slog.StringValue(severityName(level))
 	case slog.TimeKey:
 		a.Key = "timestamp"
 	case slog.MessageKey:
@@ -377,6 +548,26 @@ func replacer(groups []string, a slog.Attr) slog.Attr {
 	return a
 }
 
+// severityName maps a slog.Level to the Cloud Logging LogSeverity name.
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
+func severityName(level slog.Level) string {
+	switch level {
+	case TraceLevel, DebugLevel:
+		return "DEBUG"
+	case InfoLevel:
+		return "INFO"
+	case WarnLevel:
+		return "WARNING"
+	case ErrorLevel, DPanicLevel:
+		return "ERROR"
+	case PanicLevel:
+		return "CRITICAL"
+	case FatalLevel:
+		return "EMERGENCY"
+	}
+	return level.String()
+}
+
 var stackSkipPrefixes = []string{
 	"runtime/debug.",
 	"log/slog.",
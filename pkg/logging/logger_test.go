@@ -4,9 +4,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dentech-floss/logging/pkg/logging"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -39,3 +51,444 @@ func TestLogger(t *testing.T) {
 		t.Errorf("Expected trace ID not found or incorrect, got: %v", traceID)
 	}
 }
+
+func TestLoggerRedaction(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.DebugLevel,
+		Redaction:   logging.DefaultRedactionPolicy(),
+
+		Output: &buf,
+	})
+
+	ctx := logging.ContextWithLoggerFields(context.Background(), []slog.Attr{
+		logging.String("authorization", "Bearer abc.def"),
+	})
+
+	logger.With(logging.String("password", "hunter2")).InfoContext(
+		ctx,
+		"patient 19900101-1234 updated",
+		logging.String("note", "token is Bearer xyz"),
+		logging.Any("body", json.RawMessage(`{"user":{"SSN":"850709-9805"},"name":"x"}`)),
+		logging.Any("user", struct {
+			Name     string
+			Password string
+		}{Name: "y", Password: "s3cr3t"}),
+		logging.Any("headers", map[string]string{"Cookie": "session=c00kie", "Accept": "text/plain"}),
+		logging.Any("tokens", &[]map[string]any{{"refresh_token": "r3fresh"}}),
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "abc.def", "xyz", "19900101-1234", "850709-9805", "s3cr3t", "c00kie", "r3fresh"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %q to be redacted, got: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"name":"x"`) || !strings.Contains(out, `"Name":"y"`) || !strings.Contains(out, `"Accept":"text/plain"`) {
+		t.Errorf("expected non-sensitive JSON fields to be kept, got: %s", out)
+	}
+}
+
+func TestLoggerSpanEvents(t *testing.T) {
+	var buf bytes.Buffer
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:      "test-project",
+		ServiceName:    "test-service",
+		MinLevel:       logging.DebugLevel,
+		ErrorReporting: true,
+
+		Output: &buf,
+	})
+
+	ctx, span := tracer.Start(context.Background(), "operation")
+	logger.DebugContext(ctx, "below the span event level")
+	logger.InfoContext(ctx, "fetching appointments", logging.Int("count", 3))
+	logger.ErrorContext(ctx, "fetching appointments failed", logging.Error(errors.New("boom")))
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+
+	events := spans[0].Events()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 span events, got %d: %v", len(events), events)
+	}
+	if events[0].Name != "fetching appointments" {
+		t.Errorf("expected log message as event name, got %q", events[0].Name)
+	}
+	if events[1].Name != "exception" {
+		t.Errorf("expected error to be recorded as exception event, got %q", events[1].Name)
+	}
+	for _, attr := range events[1].Attributes {
+		if key := string(attr.Key); key == "stack_trace" || key == "@type" || strings.HasPrefix(key, "context.") {
+			t.Errorf("expected the Error Reporting fields to stay out of the span event, got %q", key)
+		}
+	}
+	if status := spans[0].Status(); status.Code != codes.Error || status.Description != "boom" {
+		t.Errorf("expected span status to be set from the error, got %+v", status)
+	}
+}
+
+func TestLoggerErrorReporting(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:      "test-project",
+		ServiceName:    "test-service",
+		MinLevel:       logging.DebugLevel,
+		ErrorReporting: true,
+
+		Output: &buf,
+	})
+
+	logger.Error("could not book appointment", logging.Error(errors.New("slot taken")))
+
+	var logMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &logMap); err != nil {
+		t.Fatalf("Failed to parse JSON log: %v", err)
+	}
+
+	if logMap["@type"] != "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent" {
+		t.Errorf("Expected ReportedErrorEvent @type, got: %v", logMap["@type"])
+	}
+	if _, ok := logMap["stacktrace"]; ok {
+		t.Errorf("Expected no free-form stacktrace field, got: %v", logMap["stacktrace"])
+	}
+	stackTrace, _ := logMap["stack_trace"].(string)
+	if !strings.HasPrefix(stackTrace, "slot taken\n\ngoroutine ") {
+		t.Errorf("Expected stack_trace to start with the error text and goroutine header, got: %q", stackTrace)
+	}
+	reportLocation, _ := logMap["context"].(map[string]any)["reportLocation"].(map[string]any)
+	if file, _ := reportLocation["filePath"].(string); !strings.HasSuffix(file, "logger_test.go") {
+		t.Errorf("Expected reportLocation to point at the caller, got: %v", reportLocation)
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.InfoLevel,
+
+		Output: &buf,
+	})
+	child := logger.With(logging.String("component", "test"))
+	handler := logging.NewLevelHandler(logger)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/loglevel", strings.NewReader(`{"level":"DEBUG","expiry":"50ms"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	buf.Reset()
+	child.Debug("visible while debug is enabled")
+	if !strings.Contains(buf.String(), "visible while debug is enabled") {
+		t.Errorf("Expected debug entry after raising the level, got: %q", buf.String())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for logger.Level() != logging.InfoLevel && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	buf.Reset()
+	child.Debug("hidden after expiry")
+	if buf.Len() != 0 {
+		t.Errorf("Expected level to revert after expiry, got: %q", buf.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loglevel", nil))
+	if !strings.Contains(rec.Body.String(), `"level":"INFO"`) {
+		t.Errorf("Expected current level in response, got: %s", rec.Body.String())
+	}
+}
+
+func TestNamedLoggerLevels(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.InfoLevel,
+		Levels: map[string]slog.Level{
+			"gorm":        logging.WarnLevel,
+			"http.client": logging.DebugLevel,
+		},
+
+		Output: &buf,
+	})
+
+	gorm := logger.Named("gorm").Named("migrations")
+	httpClient := logger.Named("http").Named("client")
+
+	gorm.Info("hidden by the gorm override")
+	if buf.Len() != 0 {
+		t.Errorf("Expected gorm.migrations to inherit the gorm level, got: %q", buf.String())
+	}
+
+	httpClient.Debug("visible through the http.client override")
+	var logMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &logMap); err != nil {
+		t.Fatalf("Failed to parse JSON log: %v", err)
+	}
+	if logMap["logger"] != "http.client" {
+		t.Errorf("Expected logger field with the dotted name, got: %v", logMap["logger"])
+	}
+
+	buf.Reset()
+	httpClient.WithGroup("req").Info("grouped", "method", "GET")
+	logMap = nil
+	if err := json.Unmarshal(buf.Bytes(), &logMap); err != nil {
+		t.Fatalf("Failed to parse JSON log: %v", err)
+	}
+	if req, _ := logMap["req"].(map[string]any); logMap["logger"] != "http.client" || req["logger"] != nil {
+		t.Errorf("Expected the logger field outside of the group, got: %v", logMap)
+	}
+	if strings.Count(buf.String(), `"logger"`) != 1 {
+		t.Errorf("Expected a single logger field, got: %s", buf.String())
+	}
+
+	if err := logger.Named("gorm").SetLevel(logging.DebugLevel, 0); err != nil {
+		t.Fatalf("Failed to set level: %v", err)
+	}
+	buf.Reset()
+	gorm.Debug("visible after lowering the gorm level")
+	if buf.Len() == 0 {
+		t.Errorf("Expected runtime level change to apply to existing child loggers")
+	}
+}
+
+func TestLoggerDevelopment(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:      "test-project",
+		ServiceName:    "test-service",
+		MinLevel:       logging.DebugLevel,
+		Development:    true,
+		ErrorReporting: true,
+
+		Output: &buf,
+	})
+
+	logger.WithGroup("request").Warn("slow request", logging.String("path", "/a b"))
+
+	out := buf.String()
+	if !strings.Contains(out, "WARN   slow request") {
+		t.Errorf("Expected aligned level and message, got: %q", out)
+	}
+	if !strings.Contains(out, `request.path="/a b"`) {
+		t.Errorf("Expected grouped, quoted attribute, got: %q", out)
+	}
+	if !strings.Contains(out, "\n    goroutine ") {
+		t.Errorf("Expected multi-line stacktrace, got: %q", out)
+	}
+
+	buf.Reset()
+	logger.Info("invoice sent", logging.String("context", "billing"), logging.String("@type", "invoice"))
+	logger.Error("invoice failed")
+	out = buf.String()
+	if !strings.Contains(out, "context=billing") || !strings.Contains(out, "@type=invoice") {
+		t.Errorf("Expected fields named like Error Reporting ones to be kept, got: %q", out)
+	}
+	if strings.Contains(out, "reportLocation") || strings.Contains(out, "ReportedErrorEvent") {
+		t.Errorf("Expected the Error Reporting fields to be left out, got: %q", out)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected DPanic to panic in development")
+		}
+	}()
+	logger.DPanic("invariant violated")
+}
+
+// blockingWriter blocks every Write until release is closed.
+type blockingWriter struct {
+	release chan struct{}
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func TestLoggerAsync(t *testing.T) {
+	out := &blockingWriter{release: make(chan struct{})}
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.DebugLevel,
+		Async: &logging.AsyncConfig{
+			QueueSize: 2,
+			Overflow:  logging.OverflowDropNewest,
+		},
+
+		Output: out,
+	})
+
+	for i := 0; i < 10; i++ {
+		logger.Info("queued", logging.Int("i", i))
+	}
+	if logger.Dropped() == 0 {
+		t.Errorf("Expected entries to be dropped while the writer is blocked")
+	}
+
+	close(out.release)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Failed to close logger: %v", err)
+	}
+
+	lines := strings.Count(out.buf.String(), "\n")
+	if uint64(lines)+logger.Dropped() != 10 {
+		t.Errorf("Expected written + dropped to be 10, got %d + %d", lines, logger.Dropped())
+	}
+}
+
+func TestLoggerAsyncCloseWhileBlocked(t *testing.T) {
+	out := &blockingWriter{release: make(chan struct{})}
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.DebugLevel,
+		Async: &logging.AsyncConfig{
+			QueueSize: 1,
+			Overflow:  logging.OverflowBlock,
+		},
+
+		Output: out,
+	})
+
+	// The first entry is taken by the background goroutine, which blocks on
+	// it, the second fills the queue and the third waits for room.
+	logger.Info("written")
+	time.Sleep(10 * time.Millisecond)
+	logger.Info("queued")
+	blocked := make(chan struct{})
+	go func() {
+		defer close(blocked)
+		logger.Info("blocked")
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan error)
+	go func() {
+		closed <- logger.Close()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(out.release)
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatalf("Failed to close logger: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected Close to return while a writer was blocked")
+	}
+	<-blocked
+
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	if lines := strings.Count(out.buf.String(), "\n"); lines != 3 {
+		t.Errorf("Expected 3 entries, got: %s", out.buf.String())
+	}
+}
+
+func TestLoggerMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	reader := sdkmetric.NewManualReader()
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:     "test-project",
+		ServiceName:   "test-service",
+		MinLevel:      logging.InfoLevel,
+		MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+
+		Output: &buf,
+	})
+
+	logger.Debug("not emitted")
+	logger.Named("gorm").Error("emitted")
+	logger.Named("gorm").Error("emitted again")
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+
+	sums := map[string]metricdata.Sum[int64]{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				sums[m.Name] = sum
+			}
+		}
+	}
+
+	records := sums["log.records"]
+	if len(records.DataPoints) != 1 || records.DataPoints[0].Value != 2 {
+		t.Fatalf("Expected 2 records in one series, got: %+v", records.DataPoints)
+	}
+	attrs := records.DataPoints[0].Attributes
+	if v, _ := attrs.Value("severity"); v.AsString() != "ERROR" {
+		t.Errorf("Expected severity attribute ERROR, got %q", v.AsString())
+	}
+	if v, _ := attrs.Value("logger"); v.AsString() != "gorm" {
+		t.Errorf("Expected logger attribute gorm, got %q", v.AsString())
+	}
+
+	written := sums["log.bytes"]
+	if len(written.DataPoints) != 1 || written.DataPoints[0].Value != int64(buf.Len()) {
+		t.Errorf("Expected %d bytes written, got: %+v", buf.Len(), written.DataPoints)
+	}
+}
+
+func TestLoggerLogDepth(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.InfoLevel,
+
+		Output: &buf,
+	})
+
+	logHelper := func(msg string) {
+		logger.LogDepth(context.Background(), 1, logging.InfoLevel, msg)
+	}
+	logHelper("from helper")
+
+	var entry struct {
+		SourceLocation struct {
+			Function string `json:"function"`
+		} `json:"logging.googleapis.com/sourceLocation"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON log: %v", err)
+	}
+	if !strings.HasSuffix(entry.SourceLocation.Function, "TestLoggerLogDepth") {
+		t.Errorf("Expected the helper's caller as source location, got %q", entry.SourceLocation.Function)
+	}
+}
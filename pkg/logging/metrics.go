@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	instrumentationName = "github.com/dentech-floss/logging/pkg/logging"
+
+	metricRecords     = "log.records"
+	metricBytes       = "log.bytes"
+	metricDropped     = "log.records.dropped"
+	metricWriteErrors = "log.write.errors"
+
+	metricAttrSeverity = "severity"
+	metricAttrLogger   = "logger"
+)
+
+// logMetrics holds the OpenTelemetry instruments describing the log volume
+// of a Logger.
+type logMetrics struct {
+	records     metric.Int64Counter
+	bytes       metric.Int64Counter
+	dropped     metric.Int64Counter
+	writeErrors metric.Int64Counter
+}
+
+func newLogMetrics(provider metric.MeterProvider) *logMetrics {
+	meter := provider.Meter(instrumentationName)
+	m := &logMetrics{}
+
+	var err error
+	if m.records, err = meter.Int64Counter(
+		metricRecords,
+		metric.WithDescription("Number of log records emitted, per severity and logger name."),
+		metric.WithUnit("{record}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if m.bytes, err = meter.Int64Counter(
+		metricBytes,
+		metric.WithDescription("Number of bytes written to the log output."),
+		metric.WithUnit("By"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if m.dropped, err = meter.Int64Counter(
+		metricDropped,
+		metric.WithDescription("Number of log records dropped because the asynchronous queue was full."),
+		metric.WithUnit("{record}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+	if m.writeErrors, err = meter.Int64Counter(
+		metricWriteErrors,
+		metric.WithDescription("Number of failed writes to the log output."),
+		metric.WithUnit("{error}"),
+	); err != nil {
+		otel.Handle(err)
+	}
+
+	return m
+}
+
+// metricsHandler counts the records passing through it. It sits right below
+// levelHandler, so it only sees enabled records and picks the logger name up
+// from the "logger" field Named adds there.
+type metricsHandler struct {
+	slog.Handler
+	metrics *logMetrics
+	name    string
+}
+
+func (h *metricsHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.metrics.records.Add(ctx, 1, metric.WithAttributes(
+		attribute.String(metricAttrSeverity, severityName(record.Level)),
+		attribute.String(metricAttrLogger, h.name),
+	))
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *metricsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	name := h.name
+	for _, a := range attrs {
+		if a.Key == logFieldLoggerName {
+			name = a.Value.String()
+		}
+	}
+	return &metricsHandler{
+		Handler: h.Handler.WithAttrs(attrs),
+		metrics: h.metrics,
+		name:    name,
+	}
+}
+
+func (h *metricsHandler) WithGroup(name string) slog.Handler {
+	return &metricsHandler{
+		Handler: h.Handler.WithGroup(name),
+		metrics: h.metrics,
+		name:    h.name,
+	}
+}
+
+// meteredWriter counts the bytes written to, and the errors returned by, the
+// actual log output.
+type meteredWriter struct {
+	out     io.Writer
+	metrics *logMetrics
+}
+
+func (w *meteredWriter) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	w.metrics.bytes.Add(context.Background(), int64(n))
+	if err != nil {
+		w.metrics.writeErrors.Add(context.Background(), 1)
+	}
+	return n, err
+}
+
+// Sync passes Sync on to the output, see syncWriter.
+func (w *meteredWriter) Sync() error {
+	syncWriter(w.out)
+	return nil
+}
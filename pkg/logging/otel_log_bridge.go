@@ -0,0 +1,278 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+const (
+	otelCodeFilePathKey     = "code.file.path"
+	otelCodeLineNumberKey   = "code.line.number"
+	otelCodeFunctionNameKey = "code.function.name"
+)
+
+// otelLogHandler converts records to OpenTelemetry log records and emits
+// them through an OpenTelemetry Logger. Trace and span correlation is taken
+// from the context by the OpenTelemetry SDK, using its native fields instead
+// of the logging.googleapis.com keys.
+type otelLogHandler struct {
+	logger otellog.Logger
+
+	// scopes[0] holds the top-level attributes added with WithAttrs,
+	// scopes[i] the ones added inside groups[:i].
+	groups []string
+	scopes [][]attribute.KeyValue
+}
+
+func newOtelLogHandler(provider otellog.LoggerProvider) *otelLogHandler {
+	return &otelLogHandler{
+		logger: provider.Logger(instrumentationName),
+		scopes: [][]attribute.KeyValue{nil},
+	}
+}
+
+func (h *otelLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.logger.Enabled(ctx, otellog.EnabledParameters{Severity: otelSeverity(level)})
+}
+
+func (h *otelLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	var r otellog.Record
+	r.SetTimestamp(record.Time)
+	r.SetSeverity(otelSeverity(record.Level))
+	r.SetSeverityText(severityName(record.Level))
+	r.SetBody(attribute.StringValue(record.Message))
+
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		r.AddAttributes(
+			attribute.String(otelCodeFilePathKey, frame.File),
+			attribute.Int(otelCodeLineNumberKey, frame.Line),
+			attribute.String(otelCodeFunctionNameKey, frame.Function),
+		)
+	}
+
+	innermost := len(h.scopes) - 1
+	kvs := append([]attribute.KeyValue(nil), h.scopes[innermost]...)
+	record.Attrs(func(a slog.Attr) bool {
+		if err, ok := a.Value.Any().(error); ok && a.Key == "error" {
+			r.SetErr(err)
+		}
+		kvs = appendOtelKeyValues(kvs, a)
+		return true
+	})
+	for i := innermost - 1; i >= 0; i-- {
+		if len(kvs) != 0 {
+			kvs = append(append([]attribute.KeyValue(nil), h.scopes[i]...), attribute.Map(h.groups[i], kvs...))
+		} else {
+			kvs = h.scopes[i]
+		}
+	}
+	r.AddAttributes(kvs...)
+
+	h.logger.Emit(ctx, r)
+	return nil
+}
+
+func (h *otelLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	innermost := len(h.scopes) - 1
+	scope := append([]attribute.KeyValue(nil), h.scopes[innermost]...)
+	for _, a := range attrs {
+		if a.Key == "serviceContext" {
+			// Described by the resource of the LoggerProvider instead.
+			continue
+		}
+		scope = appendOtelKeyValues(scope, a)
+	}
+
+	clone := *h
+	clone.scopes = append(append([][]attribute.KeyValue(nil), h.scopes[:innermost]...), scope)
+	return &clone
+}
+
+func (h *otelLogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := *h
+	clone.groups = append(append([]string(nil), h.groups...), name)
+	clone.scopes = append(append([][]attribute.KeyValue(nil), h.scopes...), nil)
+	return &clone
+}
+
+// otelSeverity maps a slog.Level to an OpenTelemetry severity, keeping our
+// levels above ErrorLevel within the defined range.
+func otelSeverity(level slog.Level) otellog.Severity {
+	switch {
+	case level >= FatalLevel:
+		return otellog.SeverityFatal4
+	case level >= PanicLevel:
+		return otellog.SeverityFatal
+	case level >= DPanicLevel:
+		return otellog.SeverityError4
+	case level < DebugLevel-4:
+		return otellog.SeverityTrace1
+	}
+	const offset = slog.Level(otellog.SeverityDebug) - DebugLevel
+	return otellog.Severity(level + offset)
+}
+
+// appendOtelKeyValues converts a slog.Attr and appends it to kvs. Groups
+// become nested maps, except for groups without a key whose attributes are
+// inlined, as slog handlers do.
+func appendOtelKeyValues(kvs []attribute.KeyValue, a slog.Attr) []attribute.KeyValue {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return kvs
+	}
+	if a.Value.Kind() == slog.KindGroup && a.Key == "" {
+		for _, child := range a.Value.Group() {
+			kvs = appendOtelKeyValues(kvs, child)
+		}
+		return kvs
+	}
+	return append(kvs, attribute.KeyValue{Key: attribute.Key(a.Key), Value: otelValue(a.Value)})
+}
+
+func otelValue(v slog.Value) attribute.Value {
+	switch v.Kind() {
+	case slog.KindString:
+		return attribute.StringValue(v.String())
+	case slog.KindInt64:
+		return attribute.Int64Value(v.Int64())
+	case slog.KindUint64:
+		if u := v.Uint64(); u <= math.MaxInt64 {
+			return attribute.Int64Value(int64(u))
+		}
+		return attribute.StringValue(v.String())
+	case slog.KindFloat64:
+		return attribute.Float64Value(v.Float64())
+	case slog.KindBool:
+		return attribute.BoolValue(v.Bool())
+	case slog.KindDuration:
+		return attribute.Int64Value(v.Duration().Nanoseconds())
+	case slog.KindTime:
+		return attribute.StringValue(v.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		var kvs []attribute.KeyValue
+		for _, a := range v.Group() {
+			kvs = appendOtelKeyValues(kvs, a)
+		}
+		return attribute.MapValue(kvs...)
+	}
+
+	switch value := v.Any().(type) {
+	case error:
+		return attribute.StringValue(value.Error())
+	case json.RawMessage:
+		var doc any
+		if err := json.Unmarshal(value, &doc); err == nil {
+			return otelJSONValue(doc)
+		}
+		return attribute.StringValue(string(value))
+	case []byte:
+		return attribute.ByteSliceValue(value)
+	case fmt.Stringer:
+		return attribute.StringValue(value.String())
+	}
+	return attribute.StringValue(fmt.Sprintf("%+v", v.Any()))
+}
+
+// otelJSONValue converts a decoded JSON document, e.g. from Proto, into a
+// structured OpenTelemetry value.
+func otelJSONValue(doc any) attribute.Value {
+	switch value := doc.(type) {
+	case map[string]any:
+		kvs := make([]attribute.KeyValue, 0, len(value))
+		for k, v := range value {
+			kvs = append(kvs, attribute.KeyValue{Key: attribute.Key(k), Value: otelJSONValue(v)})
+		}
+		return attribute.MapValue(kvs...)
+	case []any:
+		values := make([]attribute.Value, len(value))
+		for i, v := range value {
+			values[i] = otelJSONValue(v)
+		}
+		return attribute.SliceValue(values...)
+	case string:
+		return attribute.StringValue(value)
+	case float64:
+		return attribute.Float64Value(value)
+	case bool:
+		return attribute.BoolValue(value)
+	}
+	return attribute.Value{}
+}
+
+// contextFieldsHandler adds the fields from ContextWithLoggerFields to every
+// record. spanContextLogHandler does the same for the GCP output.
+type contextFieldsHandler struct {
+	slog.Handler
+}
+
+func (h *contextFieldsHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs := LoggerFieldsFromContext(ctx); len(attrs) != 0 {
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextFieldsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextFieldsHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextFieldsHandler) WithGroup(name string) slog.Handler {
+	return &contextFieldsHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// fanoutHandler passes every record to each of its handlers.
+type fanoutHandler struct {
+	handlers []slog.Handler
+}
+
+func (h *fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if !handler.Enabled(ctx, record.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, record.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return &fanoutHandler{handlers: handlers}
+}
+
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return &fanoutHandler{handlers: handlers}
+}
@@ -0,0 +1,113 @@
+package logging_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/dentech-floss/logging/pkg/logging"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpReceiver is an in-process OTLP/HTTP logs endpoint.
+type otlpReceiver struct {
+	mu      sync.Mutex
+	records []*logspb.LogRecord
+}
+
+func (r *otlpReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var export collogspb.ExportLogsServiceRequest
+	if err := proto.Unmarshal(body, &export); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	for _, rl := range export.GetResourceLogs() {
+		for _, sl := range rl.GetScopeLogs() {
+			r.records = append(r.records, sl.GetLogRecords()...)
+		}
+	}
+	r.mu.Unlock()
+
+	response, _ := proto.Marshal(&collogspb.ExportLogsServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(response)
+}
+
+func TestLoggerOtelLogBridge(t *testing.T) {
+	receiver := &otlpReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	ctx := context.Background()
+	exporter, err := otlploghttp.New(ctx, otlploghttp.WithEndpointURL(server.URL+"/v1/logs"))
+	if err != nil {
+		t.Fatalf("Failed to create exporter: %v", err)
+	}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	defer func() { _ = provider.Shutdown(ctx) }()
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:      "test-project",
+		ServiceName:    "test-service",
+		MinLevel:       logging.InfoLevel,
+		LoggerProvider: provider,
+
+		Output: io.Discard,
+	})
+
+	traceID := trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}))
+
+	logger.Named("booking").WarnContext(ctx, "slot almost full", logging.Int("free", 1))
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	if len(receiver.records) != 1 {
+		t.Fatalf("Expected 1 exported record, got %d", len(receiver.records))
+	}
+
+	record := receiver.records[0]
+	if record.GetBody().GetStringValue() != "slot almost full" {
+		t.Errorf("Expected message as body, got %v", record.GetBody())
+	}
+	if record.GetSeverityText() != "WARNING" || record.GetSeverityNumber() != logspb.SeverityNumber_SEVERITY_NUMBER_WARN {
+		t.Errorf("Expected WARNING severity, got %s/%v", record.GetSeverityText(), record.GetSeverityNumber())
+	}
+	if trace.TraceID(record.GetTraceId()) != traceID {
+		t.Errorf("Expected native trace correlation, got trace id %x", record.GetTraceId())
+	}
+
+	attrs := map[string]bool{}
+	for _, kv := range record.GetAttributes() {
+		attrs[kv.GetKey()] = true
+	}
+	for _, key := range []string{"free", "logger", "code.file.path"} {
+		if !attrs[key] {
+			t.Errorf("Expected attribute %q, got %v", key, attrs)
+		}
+	}
+	for _, key := range []string{"logging.googleapis.com/trace", "serviceContext", "stacktrace"} {
+		if attrs[key] {
+			t.Errorf("Expected no GCP specific attribute %q", key)
+		}
+	}
+}
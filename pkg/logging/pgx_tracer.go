@@ -0,0 +1,337 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	logFieldSQLState      = "sqlstate"
+	logFieldBatchSize     = "batch_size"
+	logFieldStatementName = "statement_name"
+	logFieldDBHost        = "db_host"
+	logFieldDBName        = "db_name"
+)
+
+// PgxTracer logs the queries, batches, copies, prepares and connects of pgx
+// v5, with the same behavior and fields as GormLogger. Entries are logged
+// with the Logger of the context, if any, else with Logger.
+//
+//	config.ConnConfig.Tracer = logging.NewPgxTracer(logger)
+//
+// pgx takes a single tracer, use its multitracer package to combine it with
+// e.g. an OpenTelemetry tracer.
+type PgxTracer struct {
+	Logger *Logger
+	// LogAll logs every event at DEBUG. Failures are always logged at ERROR.
+	LogAll bool
+	// SlowThreshold logs queries, batches and copies running longer at
+	// WARNING. Zero disables it.
+	SlowThreshold time.Duration
+	// Params controls how bound values are logged. Queries are always logged
+	// parameterized.
+	Params SQLParamsMode
+	// ParamsHashKey is the HMAC key used with SQLParamsHash.
+	ParamsHashKey []byte
+	// NPlusOneThreshold, see GormLogger.NPlusOneThreshold.
+	NPlusOneThreshold int
+}
+
+var (
+	_ pgx.QueryTracer    = (*PgxTracer)(nil)
+	_ pgx.BatchTracer    = (*PgxTracer)(nil)
+	_ pgx.CopyFromTracer = (*PgxTracer)(nil)
+	_ pgx.PrepareTracer  = (*PgxTracer)(nil)
+	_ pgx.ConnectTracer  = (*PgxTracer)(nil)
+)
+
+func NewPgxTracer(logger *Logger) *PgxTracer {
+	return &PgxTracer{
+		Logger:            logger,
+		SlowThreshold:     defaultSlowThreshold,
+		Params:            SQLParamsRedact,
+		NPlusOneThreshold: defaultNPlusOneThreshold,
+	}
+}
+
+type pgxTraceContextKey struct{}
+
+// pgxTraceData is carried from a Start to the matching End call through the
+// returned context.
+type pgxTraceData struct {
+	startTime time.Time
+	sql       string
+	args      []any
+
+	// copy
+	table string
+
+	// batch
+	lastTime time.Time
+	size     int
+	failed   bool
+
+	// prepare and connect
+	name     string
+	host     string
+	database string
+}
+
+func pgxTraceFromContext(ctx context.Context) *pgxTraceData {
+	if data, ok := ctx.Value(pgxTraceContextKey{}).(*pgxTraceData); ok {
+		return data
+	}
+	return &pgxTraceData{startTime: time.Now()}
+}
+
+func (t *PgxTracer) TraceQueryStart(
+	ctx context.Context,
+	_ *pgx.Conn,
+	data pgx.TraceQueryStartData,
+) context.Context {
+	return context.WithValue(ctx, pgxTraceContextKey{}, &pgxTraceData{
+		startTime: time.Now(),
+		sql:       data.SQL,
+		args:      data.Args,
+	})
+}
+
+func (t *PgxTracer) TraceQueryEnd(
+	ctx context.Context,
+	_ *pgx.Conn,
+	data pgx.TraceQueryEndData,
+) {
+	trace := pgxTraceFromContext(ctx)
+	t.trace(ctx, time.Since(trace.startTime), sqlQuery{
+		sql:    trace.sql,
+		params: pgxParams(trace.args),
+		rows:   data.CommandTag.RowsAffected(),
+	}, data.Err)
+}
+
+func (t *PgxTracer) TraceBatchStart(
+	ctx context.Context,
+	_ *pgx.Conn,
+	data pgx.TraceBatchStartData,
+) context.Context {
+	now := time.Now()
+	trace := &pgxTraceData{startTime: now, lastTime: now}
+	if data.Batch != nil {
+		trace.size = data.Batch.Len()
+	}
+	return context.WithValue(ctx, pgxTraceContextKey{}, trace)
+}
+
+// TraceBatchQuery logs a query of a batch, taking the time since the
+// previous one as its duration.
+func (t *PgxTracer) TraceBatchQuery(
+	ctx context.Context,
+	_ *pgx.Conn,
+	data pgx.TraceBatchQueryData,
+) {
+	trace := pgxTraceFromContext(ctx)
+	now := time.Now()
+	elapsed := now.Sub(trace.lastTime)
+	trace.lastTime = now
+	if data.Err != nil {
+		trace.failed = true
+	}
+	t.trace(ctx, elapsed, sqlQuery{
+		sql:    data.SQL,
+		params: pgxParams(data.Args),
+		rows:   data.CommandTag.RowsAffected(),
+	}, data.Err)
+}
+
+func (t *PgxTracer) TraceBatchEnd(
+	ctx context.Context,
+	_ *pgx.Conn,
+	data pgx.TraceBatchEndData,
+) {
+	trace := pgxTraceFromContext(ctx)
+	elapsed := time.Since(trace.startTime)
+	fields := []any{
+		Duration("duration", elapsed),
+		Int(logFieldBatchSize, trace.size),
+	}
+
+	log := t.logger(ctx)
+	switch {
+	case data.Err != nil && !trace.failed:
+		// Not already logged with the failed query.
+		log.LogPC(ctx, sqlCallerPC(), ErrorLevel, "sql batch error trace", append(pgxErrorFields(data.Err), fields...)...)
+	case t.SlowThreshold != 0 && elapsed > t.SlowThreshold:
+		log.LogPC(ctx, sqlCallerPC(), WarnLevel, "sql slow batch trace", fields...)
+	case t.LogAll:
+		log.LogPC(ctx, sqlCallerPC(), DebugLevel, "sql batch trace", fields...)
+	}
+}
+
+func (t *PgxTracer) TraceCopyFromStart(
+	ctx context.Context,
+	_ *pgx.Conn,
+	data pgx.TraceCopyFromStartData,
+) context.Context {
+	trace := &pgxTraceData{
+		startTime: time.Now(),
+		sql: fmt.Sprintf(
+			"COPY %s (%s) FROM STDIN",
+			data.TableName.Sanitize(),
+			strings.Join(data.ColumnNames, ", "),
+		),
+	}
+	// The table, not the schema of a qualified name.
+	if len(data.TableName) != 0 {
+		trace.table = data.TableName[len(data.TableName)-1]
+	}
+	return context.WithValue(ctx, pgxTraceContextKey{}, trace)
+}
+
+func (t *PgxTracer) TraceCopyFromEnd(
+	ctx context.Context,
+	_ *pgx.Conn,
+	data pgx.TraceCopyFromEndData,
+) {
+	trace := pgxTraceFromContext(ctx)
+	t.trace(ctx, time.Since(trace.startTime), sqlQuery{
+		sql:   trace.sql,
+		table: trace.table,
+		rows:  data.CommandTag.RowsAffected(),
+	}, data.Err)
+}
+
+func (t *PgxTracer) TracePrepareStart(
+	ctx context.Context,
+	_ *pgx.Conn,
+	data pgx.TracePrepareStartData,
+) context.Context {
+	return context.WithValue(ctx, pgxTraceContextKey{}, &pgxTraceData{
+		startTime: time.Now(),
+		sql:       data.SQL,
+		name:      data.Name,
+	})
+}
+
+func (t *PgxTracer) TracePrepareEnd(
+	ctx context.Context,
+	_ *pgx.Conn,
+	data pgx.TracePrepareEndData,
+) {
+	trace := pgxTraceFromContext(ctx)
+	fields := []any{
+		Duration("duration", time.Since(trace.startTime)),
+		String(logFieldSQL, normalizeSQL(trace.sql)),
+		String(logFieldQueryFingerprint, queryFingerprint(trace.sql)),
+	}
+	if trace.name != "" {
+		fields = append(fields, String(logFieldStatementName, trace.name))
+	}
+
+	log := t.logger(ctx)
+	switch {
+	case data.Err != nil:
+		log.LogPC(ctx, sqlCallerPC(), ErrorLevel, "sql prepare error trace", append(pgxErrorFields(data.Err), fields...)...)
+	case t.LogAll && !data.AlreadyPrepared:
+		log.LogPC(ctx, sqlCallerPC(), DebugLevel, "sql prepare trace", fields...)
+	}
+}
+
+func (t *PgxTracer) TraceConnectStart(
+	ctx context.Context,
+	data pgx.TraceConnectStartData,
+) context.Context {
+	trace := &pgxTraceData{startTime: time.Now()}
+	if data.ConnConfig != nil {
+		trace.host, trace.database = data.ConnConfig.Host, data.ConnConfig.Database
+	}
+	return context.WithValue(ctx, pgxTraceContextKey{}, trace)
+}
+
+func (t *PgxTracer) TraceConnectEnd(
+	ctx context.Context,
+	data pgx.TraceConnectEndData,
+) {
+	trace := pgxTraceFromContext(ctx)
+	fields := []any{
+		Duration("duration", time.Since(trace.startTime)),
+		String(logFieldDBHost, trace.host),
+		String(logFieldDBName, trace.database),
+	}
+
+	log := t.logger(ctx)
+	switch {
+	case data.Err != nil:
+		log.LogPC(ctx, sqlCallerPC(), ErrorLevel, "sql connect error trace", append(pgxErrorFields(data.Err), fields...)...)
+	case t.LogAll:
+		log.LogPC(ctx, sqlCallerPC(), DebugLevel, "sql connect trace", fields...)
+	}
+}
+
+func (t *PgxTracer) trace(
+	ctx context.Context,
+	elapsed time.Duration,
+	query sqlQuery,
+	err error,
+) {
+	var extra []any
+	if code := pgxSQLState(err); code != "" {
+		extra = append(extra, String(logFieldSQLState, code))
+	}
+
+	tracer := sqlTracer{
+		logErrors:         true,
+		logWarnings:       true,
+		logAll:            t.LogAll,
+		slowThreshold:     t.SlowThreshold,
+		parameterized:     true,
+		params:            t.Params,
+		hashKey:           t.ParamsHashKey,
+		nPlusOneThreshold: t.NPlusOneThreshold,
+	}
+	tracer.trace(ctx, t.logger(ctx), elapsed, err, func() sqlQuery {
+		return query
+	}, extra...)
+}
+
+func (t *PgxTracer) logger(ctx context.Context) *Logger {
+	if log := LoggerFromContext(ctx); log != nil {
+		return log
+	}
+	return t.Logger
+}
+
+// pgxParams drops the query execution mode or result format arguments pgx
+// accepts in front of the bound values.
+func pgxParams(args []any) []any {
+	for len(args) > 0 {
+		switch args[0].(type) {
+		case pgx.QueryExecMode, pgx.QueryResultFormats, pgx.QueryResultFormatsByOID, pgx.QueryRewriter:
+			args = args[1:]
+			continue
+		}
+		break
+	}
+	return args
+}
+
+func pgxSQLState(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
+
+func pgxErrorFields(err error) []any {
+	fields := []any{Error(err)}
+	if code := pgxSQLState(err); code != "" {
+		fields = append(fields, String(logFieldSQLState, code))
+	}
+	return fields
+}
@@ -0,0 +1,83 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dentech-floss/logging/pkg/logging"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestPgxTracer(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.DebugLevel,
+
+		Output: &buf,
+	})
+	tracer := logging.NewPgxTracer(logger)
+	tracer.SlowThreshold = 10 * time.Millisecond
+	ctx := context.Background()
+
+	// A failing insert.
+	queryCtx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{
+		SQL:  "INSERT INTO patients (personal_number) VALUES ($1)",
+		Args: []any{pgx.QueryExecModeExec, "19850709-9805"},
+	})
+	tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{
+		Err: &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"},
+	})
+
+	// A slow select.
+	queryCtx = tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{
+		SQL: "SELECT * FROM appointments WHERE patient_id = $1",
+	})
+	time.Sleep(20 * time.Millisecond)
+	tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 3")})
+
+	// A fast copy, only logged with LogAll.
+	tracer.LogAll = true
+	copyCtx := tracer.TraceCopyFromStart(ctx, nil, pgx.TraceCopyFromStartData{
+		TableName:   pgx.Identifier{"public", "invoices"},
+		ColumnNames: []string{"id", "amount"},
+	})
+	tracer.TraceCopyFromEnd(copyCtx, nil, pgx.TraceCopyFromEndData{CommandTag: pgconn.NewCommandTag("COPY 2")})
+
+	if strings.Contains(buf.String(), "19850709-9805") {
+		t.Fatalf("Expected bound values to stay out of the log, got: %s", buf.String())
+	}
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse JSON log %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got: %v", entries)
+	}
+
+	failed, slow, copied := entries[0], entries[1], entries[2]
+	if failed["message"] != "sql error trace" || failed["sqlstate"] != "23505" || failed["db_operation"] != "INSERT" {
+		t.Errorf("Expected error entry with SQLSTATE, got: %v", failed)
+	}
+	if params, _ := failed["sql_params"].([]any); len(params) != 1 {
+		t.Errorf("Expected the exec mode argument to be dropped, got: %v", failed["sql_params"])
+	}
+	if slow["message"] != "sql slow query trace" || slow["rows"] != float64(3) || slow["db_table"] != "appointments" {
+		t.Errorf("Expected slow query entry, got: %v", slow)
+	}
+	if copied["message"] != "sql debug trace" || copied["rows"] != float64(2) || copied["db_table"] != "invoices" {
+		t.Errorf("Expected copy entry, got: %v", copied)
+	}
+}
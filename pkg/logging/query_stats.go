@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	logFieldDBQueries = "db_queries"
+	logFieldDBTimeMs  = "db_time_ms"
+)
+
+// QueryStats accumulates the database queries run within a request. The
+// HTTP middleware and the gRPC server interceptors put one into the request
+// context and add its totals to their entries. It is safe for concurrent use.
+type QueryStats struct {
+	mu           sync.Mutex
+	queries      int
+	duration     time.Duration
+	fingerprints map[string]int
+}
+
+type queryStatsContextKey struct{}
+
+// ContextWithQueryStats returns a context carrying new QueryStats, which
+// GormLogger updates for every query run with the context.
+func ContextWithQueryStats(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryStatsContextKey{}, &QueryStats{})
+}
+
+// QueryStatsFromContext returns the QueryStats of the context, or nil.
+func QueryStatsFromContext(ctx context.Context) *QueryStats {
+	stats, _ := ctx.Value(queryStatsContextKey{}).(*QueryStats)
+	return stats
+}
+
+// Queries returns the number of queries run.
+func (s *QueryStats) Queries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries
+}
+
+// Duration returns the total time spent running the queries.
+func (s *QueryStats) Duration() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.duration
+}
+
+// add records a query and returns the number of times its fingerprint has
+// been run so far.
+func (s *QueryStats) add(fingerprint string, duration time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries++
+	s.duration += duration
+	if s.fingerprints == nil {
+		s.fingerprints = map[string]int{}
+	}
+	s.fingerprints[fingerprint]++
+	return s.fingerprints[fingerprint]
+}
+
+// contextWithQueryStats adds QueryStats to the context unless it already
+// carries some, e.g. from an outer middleware.
+func contextWithQueryStats(ctx context.Context) (context.Context, *QueryStats) {
+	if stats := QueryStatsFromContext(ctx); stats != nil {
+		return ctx, stats
+	}
+	ctx = ContextWithQueryStats(ctx)
+	return ctx, QueryStatsFromContext(ctx)
+}
+
+// appendQueryStats adds the db_queries and db_time_ms fields to a request
+// entry, if any query was run.
+func appendQueryStats(fields []slog.Attr, stats *QueryStats) []slog.Attr {
+	if stats == nil {
+		return fields
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	if stats.queries == 0 {
+		return fields
+	}
+	return append(
+		fields,
+		Int(logFieldDBQueries, stats.queries),
+		Int64(logFieldDBTimeMs, stats.duration.Milliseconds()),
+	)
+}
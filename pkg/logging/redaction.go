@@ -0,0 +1,290 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const defaultRedactionReplacement = "[REDACTED]"
+
+// RedactionPolicy describes which values must never reach the log output.
+//
+// The policy is enforced by the handler created in NewLogger, so it applies
+// to attributes built with String, Any, Proto etc., to attributes added via
+// Logger.With and to attributes carried by ContextWithLoggerFields alike.
+type RedactionPolicy struct {
+	// Keys lists attribute keys whose values are replaced entirely. Matching
+	// is case-insensitive and applies at any group depth, including keys
+	// inside JSON produced by Proto and the fields of maps and structs
+	// passed to Any. A redacted map or struct is logged in its JSON form.
+	Keys []string
+	// ValuePatterns are matched against string values, error messages and
+	// the log message itself. Every match is replaced.
+	ValuePatterns []*regexp.Regexp
+	// Maskers maps attribute keys (case-insensitive) to custom masking
+	// functions. A masker takes precedence over Keys.
+	Maskers map[string]func(slog.Value) slog.Value
+	// Replacement is the text substituted for redacted values. Defaults to
+	// "[REDACTED]".
+	Replacement string
+}
+
+// DefaultRedactionPolicy returns a policy covering the most common
+// credentials and personal identifiers. Callers are free to extend the
+// returned policy before passing it to NewLogger.
+func DefaultRedactionPolicy() *RedactionPolicy {
+	return &RedactionPolicy{
+		Keys: []string{
+			"password",
+			"passwd",
+			"secret",
+			"client_secret",
+			"token",
+			"access_token",
+			"refresh_token",
+			"id_token",
+			"api_key",
+			"apikey",
+			"authorization",
+			"proxy-authorization",
+			"cookie",
+			"set-cookie",
+			"ssn",
+			"personal_number",
+		},
+		ValuePatterns: []*regexp.Regexp{
+			// Bearer and Basic credentials, e.g. from a dumped header.
+			regexp.MustCompile(`(?i)\b(bearer|basic)\s+[a-z0-9._~+/=-]+`),
+			// Swedish personal identity numbers (personnummer).
+			regexp.MustCompile(`\b(?:(?:19|20)\d{6}[-+]?|\d{6}[-+])\d{4}\b`),
+		},
+	}
+}
+
+// MaskAllButLast returns a masker that keeps the last n characters of a
+// string value and replaces the rest with '*'.
+func MaskAllButLast(n int) func(slog.Value) slog.Value {
+	return func(v slog.Value) slog.Value {
+		s := v.String()
+		if len(s) <= n {
+			return slog.StringValue(strings.Repeat("*", len(s)))
+		}
+		return slog.StringValue(strings.Repeat("*", len(s)-n) + s[len(s)-n:])
+	}
+}
+
+// redactor is the compiled, immutable form of a RedactionPolicy.
+type redactor struct {
+	keys        map[string]struct{}
+	maskers     map[string]func(slog.Value) slog.Value
+	patterns    []*regexp.Regexp
+	replacement string
+}
+
+func newRedactor(policy *RedactionPolicy) *redactor {
+	r := &redactor{
+		keys:        make(map[string]struct{}, len(policy.Keys)),
+		maskers:     make(map[string]func(slog.Value) slog.Value, len(policy.Maskers)),
+		patterns:    policy.ValuePatterns,
+		replacement: policy.Replacement,
+	}
+	if r.replacement == "" {
+		r.replacement = defaultRedactionReplacement
+	}
+	for _, key := range policy.Keys {
+		r.keys[strings.ToLower(key)] = struct{}{}
+	}
+	for key, masker := range policy.Maskers {
+		r.maskers[strings.ToLower(key)] = masker
+	}
+	return r
+}
+
+func (r *redactor) attrs(attrs []slog.Attr) []slog.Attr {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = r.attr(a)
+	}
+	return redacted
+}
+
+func (r *redactor) attr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	key := strings.ToLower(a.Key)
+
+	if masker, ok := r.maskers[key]; ok {
+		a.Value = masker(a.Value)
+		return a
+	}
+
+	if _, ok := r.keys[key]; ok {
+		a.Value = slog.StringValue(r.replacement)
+		return a
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		a.Value = slog.GroupValue(r.attrs(a.Value.Group())...)
+		return a
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.string(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case json.RawMessage:
+			a.Value = slog.AnyValue(r.rawJSON(v))
+		case error:
+			if msg := v.Error(); r.string(msg) != msg {
+				a.Value = slog.StringValue(r.string(msg))
+			}
+		default:
+			if redacted, ok := r.anyValue(v); ok {
+				a.Value = slog.AnyValue(redacted)
+			}
+		}
+	}
+	return a
+}
+
+// anyValue applies the policy to maps, structs and slices by way of their
+// JSON form, which is returned if anything matched.
+func (r *redactor) anyValue(v any) (json.RawMessage, bool) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil, false
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array:
+	default:
+		return nil, false
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	redacted := r.rawJSON(raw)
+	if bytes.Equal(redacted, raw) {
+		return nil, false
+	}
+	return redacted, true
+}
+
+func (r *redactor) string(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
+
+// rawJSON applies the policy to JSON documents such as the ones produced by
+// Proto. The original bytes are returned untouched if nothing matched.
+func (r *redactor) rawJSON(raw json.RawMessage) json.RawMessage {
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return raw
+	}
+
+	doc, changed := r.jsonValue("", doc)
+	if !changed {
+		return raw
+	}
+
+	redacted, err := json.Marshal(doc)
+	if err != nil {
+		return raw
+	}
+	return redacted
+}
+
+func (r *redactor) jsonValue(key string, v any) (any, bool) {
+	lowerKey := strings.ToLower(key)
+	if masker, ok := r.maskers[lowerKey]; ok {
+		return masker(slog.AnyValue(v)).Any(), true
+	}
+
+	if _, ok := r.keys[lowerKey]; ok {
+		return r.replacement, true
+	}
+
+	switch value := v.(type) {
+	case map[string]any:
+		changed := false
+		for k, child := range value {
+			redacted, childChanged := r.jsonValue(k, child)
+			if childChanged {
+				value[k] = redacted
+				changed = true
+			}
+		}
+		return value, changed
+	case []any:
+		changed := false
+		for i, child := range value {
+			redacted, childChanged := r.jsonValue(key, child)
+			if childChanged {
+				value[i] = redacted
+				changed = true
+			}
+		}
+		return value, changed
+	}
+
+	if s, ok := v.(string); ok {
+		if redacted := r.string(s); redacted != s {
+			return redacted, true
+		}
+	}
+	return v, false
+}
+
+// redactingHandler applies a redactor to every attribute before it reaches
+// the wrapped handler.
+type redactingHandler struct {
+	slog.Handler
+	redactor *redactor
+}
+
+func handlerWithRedaction(policy *RedactionPolicy, handler slog.Handler) *redactingHandler {
+	return &redactingHandler{
+		Handler:  handler,
+		redactor: newRedactor(policy),
+	}
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(
+		record.Time,
+		record.Level,
+		h.redactor.string(record.Message),
+		record.PC,
+	)
+	record.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactor.attr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &redactingHandler{
+		Handler:  h.Handler.WithAttrs(h.redactor.attrs(attrs)),
+		redactor: h.redactor,
+	}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{
+		Handler:  h.Handler.WithGroup(name),
+		redactor: h.redactor,
+	}
+}
@@ -0,0 +1,307 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	logFieldRedisCommand      = "redis_command"
+	logFieldRedisCommands     = "redis_commands"
+	logFieldRedisKeys         = "redis_keys"
+	logFieldRedisPipelineSize = "redis_pipeline_size"
+	logFieldRedisAddr         = "redis_addr"
+
+	// redisMaxKeys caps the key patterns logged for a command or pipeline.
+	redisMaxKeys = 10
+)
+
+// RedisHook is a go-redis v9 hook logging commands and pipelines with the
+// same levels as GormLogger: failures at ERROR, slow commands at WARNING and,
+// with LogAll, everything else at DEBUG. Only command names and key patterns
+// are logged, never values. Entries are logged with the Logger of the
+// context, if any, else with Logger.
+//
+//	client.AddHook(logging.NewRedisHook(logger))
+type RedisHook struct {
+	Logger *Logger
+	// LogAll logs every command, pipeline and dial at DEBUG. Failures are
+	// always logged at ERROR, redis.Nil is not a failure.
+	LogAll bool
+	// SlowThreshold logs commands and pipelines running longer at WARNING.
+	// Zero disables it.
+	SlowThreshold time.Duration
+	// KeyPattern overrides how a key is turned into the logged pattern, see
+	// RedisKeyPattern.
+	KeyPattern func(key string) string
+}
+
+var _ redis.Hook = (*RedisHook)(nil)
+
+func NewRedisHook(logger *Logger) *RedisHook {
+	return &RedisHook{
+		Logger:        logger,
+		SlowThreshold: 50 * time.Millisecond,
+	}
+}
+
+// RedisKeyPattern is the default mapping from a key to the logged pattern:
+// the colon separated segments of the key holding a digit or an "@", or
+// longer than 32 characters, are replaced with "*", so that
+// "session:3f2a9c:data" is logged as "session:*:data".
+func RedisKeyPattern(key string) string {
+	segments := strings.Split(key, ":")
+	for i, segment := range segments {
+		if len(segment) > 32 || strings.ContainsFunc(segment, func(r rune) bool {
+			return unicode.IsDigit(r) || r == '@'
+		}) {
+			segments[i] = "*"
+		}
+	}
+	return strings.Join(segments, ":")
+}
+
+func (h *RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		startTime := time.Now()
+		conn, err := next(ctx, network, addr)
+
+		fields := []any{
+			Duration("duration", time.Since(startTime)),
+			String(logFieldRedisAddr, addr),
+		}
+		log := h.logger(ctx)
+		switch {
+		case err != nil:
+			log.LogPC(ctx, redisCallerPC(), ErrorLevel, "redis dial error trace", append([]any{Error(err)}, fields...)...)
+		case h.LogAll:
+			log.LogPC(ctx, redisCallerPC(), DebugLevel, "redis dial trace", fields...)
+		}
+		return conn, err
+	}
+}
+
+func (h *RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		startTime := time.Now()
+		err := next(ctx, cmd)
+
+		h.trace(ctx, time.Since(startTime), redisFailure(cmd, err), func() []any {
+			return []any{
+				String(logFieldRedisCommand, cmd.FullName()),
+				Any(logFieldRedisKeys, h.keyPatterns(cmd)),
+			}
+		}, "redis error trace", "redis slow command trace", "redis debug trace")
+		return err
+	}
+}
+
+func (h *RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		startTime := time.Now()
+		err := next(ctx, cmds)
+
+		// The error of the pipeline is the one of its first failed command.
+		var failure error
+		for _, cmd := range cmds {
+			if failure = redisFailure(cmd, cmd.Err()); failure != nil {
+				break
+			}
+		}
+		h.trace(ctx, time.Since(startTime), failure, func() []any {
+			return []any{
+				Any(logFieldRedisCommands, redisCommandNames(cmds)),
+				Any(logFieldRedisKeys, h.keyPatterns(cmds...)),
+				Int(logFieldRedisPipelineSize, len(cmds)),
+			}
+		}, "redis pipeline error trace", "redis slow pipeline trace", "redis pipeline trace")
+		return err
+	}
+}
+
+// trace logs a command or pipeline, the fields are only built if an entry is
+// emitted.
+func (h *RedisHook) trace(
+	ctx context.Context,
+	elapsed time.Duration,
+	err error,
+	fields func() []any,
+	errorMsg, slowMsg, debugMsg string,
+) {
+	var (
+		level slog.Level
+		msg   string
+	)
+	switch {
+	case err != nil:
+		level, msg = ErrorLevel, errorMsg
+	case h.SlowThreshold != 0 && elapsed > h.SlowThreshold:
+		level, msg = WarnLevel, slowMsg
+	case h.LogAll:
+		level, msg = DebugLevel, debugMsg
+	default:
+		return
+	}
+
+	args := append(
+		fields(),
+		Duration("duration", elapsed),
+		Int64("duration_ms", elapsed.Milliseconds()),
+	)
+	if err != nil {
+		args = append(args, Error(err))
+	}
+	h.logger(ctx).LogPC(ctx, redisCallerPC(), level, msg, args...)
+}
+
+func (h *RedisHook) logger(ctx context.Context) *Logger {
+	if log := LoggerFromContext(ctx); log != nil {
+		return log
+	}
+	return h.Logger
+}
+
+// keyPatterns returns the distinct patterns of the keys of the commands, at
+// most redisMaxKeys of them.
+func (h *RedisHook) keyPatterns(cmds ...redis.Cmder) []string {
+	pattern := h.KeyPattern
+	if pattern == nil {
+		pattern = RedisKeyPattern
+	}
+
+	patterns := []string{}
+	seen := map[string]bool{}
+	for _, cmd := range cmds {
+		for _, key := range redisKeys(cmd) {
+			p := pattern(key)
+			if seen[p] {
+				continue
+			}
+			if len(patterns) == redisMaxKeys {
+				return patterns
+			}
+			seen[p] = true
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// redisKeylessCommands take no key, or arguments which must not be logged
+// such as the password of AUTH.
+var redisKeylessCommands = map[string]bool{
+	"auth": true, "hello": true, "ping": true, "echo": true, "select": true,
+	"quit": true, "info": true, "client": true, "config": true, "command": true,
+	"cluster": true, "dbsize": true, "flushdb": true, "flushall": true,
+	"time": true, "scan": true, "keys": true, "randomkey": true,
+	"multi": true, "exec": true, "discard": true, "unwatch": true,
+	"script": true, "function": true, "wait": true, "readonly": true,
+	"readwrite": true, "role": true, "slowlog": true, "lastsave": true,
+	"save": true, "bgsave": true, "memory": true, "debug": true,
+}
+
+// redisMultiKeyCommands take keys only.
+var redisMultiKeyCommands = map[string]bool{
+	"del": true, "unlink": true, "exists": true, "touch": true, "watch": true,
+	"mget": true, "sinter": true, "sunion": true, "sdiff": true,
+	"pfcount": true,
+}
+
+// redisKeys returns the keys of a command. Commands not known otherwise are
+// assumed to take a single key as their first argument.
+func redisKeys(cmd redis.Cmder) []string {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return nil
+	}
+
+	name := cmd.Name()
+	var keys []any
+	switch {
+	case redisKeylessCommands[name]:
+	case redisMultiKeyCommands[name]:
+		keys = args[1:]
+	case name == "mset" || name == "msetnx":
+		for i := 1; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+	case name == "eval" || name == "evalsha" || name == "eval_ro" || name == "evalsha_ro" ||
+		name == "fcall" || name == "fcall_ro":
+		// The script, the number of keys and the keys.
+		if len(args) > 2 {
+			n, _ := strconv.Atoi(redisArg(args[2]))
+			if n > 0 && 3+n <= len(args) {
+				keys = args[3 : 3+n]
+			}
+		}
+	default:
+		keys = args[1:2]
+	}
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, redisArg(key))
+	}
+	return result
+}
+
+func redisArg(arg any) string {
+	switch arg := arg.(type) {
+	case string:
+		return arg
+	case []byte:
+		return string(arg)
+	case int:
+		return strconv.Itoa(arg)
+	case int64:
+		return strconv.FormatInt(arg, 10)
+	}
+	return ""
+}
+
+// redisCommandNames returns the distinct command names of a pipeline.
+func redisCommandNames(cmds []redis.Cmder) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, cmd := range cmds {
+		name := cmd.FullName()
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// redisFailure returns the error of a command unless it is redis.Nil, returned
+// for missing keys, or a server reply to the HELLO and CLIENT commands of the
+// connection handshake, which go-redis falls back from on servers not
+// supporting them.
+func redisFailure(cmd redis.Cmder, err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	var reply redis.Error
+	if name := cmd.Name(); (name == "hello" || name == "client") && errors.As(err, &reply) {
+		return nil
+	}
+	return err
+}
+
+var redisFunctionSkipPrefixes = []string{
+	"github.com/redis/go-redis/",
+}
+
+// redisCallerPC returns the program counter of the code that ran the
+// command, i.e. the first frame outside of go-redis and this package.
+func redisCallerPC() uintptr {
+	return clientCallerPC(redisFunctionSkipPrefixes)
+}
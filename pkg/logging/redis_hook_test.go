@@ -0,0 +1,143 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dentech-floss/logging/pkg/logging"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisHook(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.DebugLevel,
+
+		Output: &buf,
+	})
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	hook := logging.NewRedisHook(logger)
+	client.AddHook(hook)
+	ctx := context.Background()
+
+	// A fast command and a missing key, neither logged by default.
+	if err := client.Set(ctx, "session:3f2a9c:data", "secret-token", 0).Err(); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+	if err := client.Get(ctx, "session:missing:data").Err(); !errors.Is(err, redis.Nil) {
+		t.Fatalf("Expected redis.Nil, got: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Expected no entries without LogAll, got: %s", buf.String())
+	}
+
+	// A failing command.
+	if err := client.LPush(ctx, "session:3f2a9c:data", "value").Err(); err == nil {
+		t.Fatal("Expected a WRONGTYPE error")
+	}
+
+	// A slow command, the hooks added later run within RedisHook.
+	client.AddHook(slowHook{delay: 60 * time.Millisecond})
+	if err := client.Get(ctx, "user:john@example.com").Err(); !errors.Is(err, redis.Nil) {
+		t.Fatalf("Expected redis.Nil, got: %v", err)
+	}
+
+	// A pipeline, logged with LogAll.
+	hook.LogAll = true
+	pipe := client.Pipeline()
+	pipe.MSet(ctx, "cache:patients:1", "a", "cache:patients:2", "b")
+	pipe.MGet(ctx, "cache:patients:1", "cache:clinics:7")
+	if _, err := pipe.Exec(ctx); err != nil {
+		t.Fatalf("Failed to run pipeline: %v", err)
+	}
+
+	for _, secret := range []string{"secret-token", "3f2a9c", "john@example.com"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Fatalf("Expected %q to stay out of the log, got: %s", secret, buf.String())
+		}
+	}
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse JSON log %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got: %v", entries)
+	}
+
+	failed, slow, pipeline := entries[0], entries[1], entries[2]
+	if failed["message"] != "redis error trace" || failed["severity"] != "ERROR" ||
+		failed["redis_command"] != "lpush" || !strings.Contains(failed["error"].(string), "WRONGTYPE") {
+		t.Errorf("Expected error entry, got: %v", failed)
+	}
+	if keys, _ := failed["redis_keys"].([]any); len(keys) != 1 || keys[0] != "session:*:data" {
+		t.Errorf("Expected key pattern, got: %v", failed["redis_keys"])
+	}
+	source, _ := failed["logging.googleapis.com/sourceLocation"].(map[string]any)
+	if file, _ := source["file"].(string); filepath.Base(file) != "redis_hook_test.go" {
+		t.Errorf("Expected the source location of the caller, got: %v", source)
+	}
+
+	if slow["message"] != "redis slow command trace" || slow["severity"] != "WARNING" || slow["redis_command"] != "get" {
+		t.Errorf("Expected slow command entry, got: %v", slow)
+	}
+	if keys, _ := slow["redis_keys"].([]any); len(keys) != 1 || keys[0] != "user:*" {
+		t.Errorf("Expected key pattern, got: %v", slow["redis_keys"])
+	}
+
+	if pipeline["message"] != "redis pipeline trace" || pipeline["severity"] != "DEBUG" || pipeline["redis_pipeline_size"] != float64(2) {
+		t.Errorf("Expected pipeline entry, got: %v", pipeline)
+	}
+	if keys, _ := pipeline["redis_keys"].([]any); len(keys) != 2 || keys[0] != "cache:patients:*" || keys[1] != "cache:clinics:*" {
+		t.Errorf("Expected distinct key patterns, got: %v", pipeline["redis_keys"])
+	}
+}
+
+// slowHook delays every command, simulating a slow server.
+type slowHook struct {
+	delay time.Duration
+}
+
+func (h slowHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h slowHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		time.Sleep(h.delay)
+		return next(ctx, cmd)
+	}
+}
+
+func (h slowHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestRedisKeyPattern(t *testing.T) {
+	for key, want := range map[string]string{
+		"session:3f2a9c:data":   "session:*:data",
+		"user:john@example.com": "user:*",
+		"feature-flags":         "feature-flags",
+	} {
+		if got := logging.RedisKeyPattern(key); got != want {
+			t.Errorf("RedisKeyPattern(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
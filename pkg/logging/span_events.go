@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	spanEventSeverityKey = "log.severity"
+	spanEventMessageKey  = "log.message"
+)
+
+// SpanEventsConfig controls how log entries are recorded as events on the
+// span found in the context. The zero value records every entry at InfoLevel
+// or above and marks the span as failed for entries at ErrorLevel or above.
+type SpanEventsConfig struct {
+	// Disabled turns span event recording off entirely.
+	Disabled bool
+	// MinLevel is the lowest level recorded as a span event.
+	MinLevel slog.Level
+	// SkipErrorStatus prevents entries at ErrorLevel or above from calling
+	// span.RecordError and setting the span status to codes.Error.
+	SkipErrorStatus bool
+}
+
+// spanEventHandler records log entries as events on the active span before
+// passing them on. It sits below the redaction handler so spans never see
+// values that are masked in the log output.
+type spanEventHandler struct {
+	slog.Handler
+	config SpanEventsConfig
+}
+
+func handlerWithSpanEvents(config *SpanEventsConfig, handler slog.Handler) slog.Handler {
+	if config == nil {
+		config = &SpanEventsConfig{}
+	}
+	if config.Disabled {
+		return handler
+	}
+	return &spanEventHandler{
+		Handler: handler,
+		config:  *config,
+	}
+}
+
+func (h *spanEventHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= h.config.MinLevel {
+		if span := trace.SpanFromContext(ctx); span.IsRecording() {
+			h.record(span, record)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *spanEventHandler) record(span trace.Span, record slog.Record) {
+	var err error
+	attrs := make([]attribute.KeyValue, 0, record.NumAttrs()+1)
+	attrs = append(attrs, attribute.String(spanEventSeverityKey, severityName(record.Level)))
+	record.Attrs(func(a slog.Attr) bool {
+		if isSpanContextAttr(a) {
+			return true
+		}
+		if e, ok := a.Value.Any().(error); ok && a.Key == "error" {
+			err = e
+		}
+		attrs = appendSpanAttributes(attrs, "", a)
+		return true
+	})
+
+	if record.Level < ErrorLevel || h.config.SkipErrorStatus {
+		span.AddEvent(record.Message, trace.WithTimestamp(record.Time), trace.WithAttributes(attrs...))
+		return
+	}
+
+	if err != nil {
+		span.RecordError(
+			err,
+			trace.WithTimestamp(record.Time),
+			trace.WithAttributes(append(attrs, attribute.String(spanEventMessageKey, record.Message))...),
+		)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+	span.AddEvent(record.Message, trace.WithTimestamp(record.Time), trace.WithAttributes(attrs...))
+	span.SetStatus(codes.Error, record.Message)
+}
+
+func (h *spanEventHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &spanEventHandler{
+		Handler: h.Handler.WithAttrs(attrs),
+		config:  h.config,
+	}
+}
+
+func (h *spanEventHandler) WithGroup(name string) slog.Handler {
+	return &spanEventHandler{
+		Handler: h.Handler.WithGroup(name),
+		config:  h.config,
+	}
+}
+
+// isSpanContextAttr reports whether the attribute was added by
+// spanContextLogHandler or errorReportingAttrs and thus only makes sense in
+// the log entry itself.
+func isSpanContextAttr(a slog.Attr) bool {
+	switch a.Key {
+	case "stacktrace", "stack_trace":
+		return true
+	}
+	return isErrorReportingAttr(a) || strings.HasPrefix(a.Key, "logging.googleapis.com/")
+}
+
+// appendSpanAttributes converts a slog.Attr into OpenTelemetry attributes,
+// flattening groups into dotted keys.
+func appendSpanAttributes(attrs []attribute.KeyValue, prefix string, a slog.Attr) []attribute.KeyValue {
+	a.Value = a.Value.Resolve()
+	key := a.Key
+	if prefix != "" {
+		key = prefix + "." + a.Key
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		for _, child := range a.Value.Group() {
+			attrs = appendSpanAttributes(attrs, key, child)
+		}
+		return attrs
+	case slog.KindString:
+		return append(attrs, attribute.String(key, a.Value.String()))
+	case slog.KindInt64:
+		return append(attrs, attribute.Int64(key, a.Value.Int64()))
+	case slog.KindUint64:
+		return append(attrs, attribute.Int64(key, int64(a.Value.Uint64())))
+	case slog.KindFloat64:
+		return append(attrs, attribute.Float64(key, a.Value.Float64()))
+	case slog.KindBool:
+		return append(attrs, attribute.Bool(key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(attrs, attribute.String(key, a.Value.Duration().String()))
+	case slog.KindTime:
+		return append(attrs, attribute.String(key, a.Value.Time().Format(time.RFC3339Nano)))
+	}
+
+	switch v := a.Value.Any().(type) {
+	case error:
+		return append(attrs, attribute.String(key, v.Error()))
+	case json.RawMessage:
+		return append(attrs, attribute.String(key, string(v)))
+	case fmt.Stringer:
+		return append(attrs, attribute.String(key, v.String()))
+	}
+
+	encoded, err := json.Marshal(a.Value.Any())
+	if err != nil {
+		return append(attrs, attribute.String(key, fmt.Sprint(a.Value.Any())))
+	}
+	return append(attrs, attribute.String(key, string(encoded)))
+}
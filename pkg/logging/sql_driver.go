@@ -0,0 +1,460 @@
+package logging
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// The errors database/sql returns for the transaction options a driver
+// without driver.ConnBeginTx cannot honor.
+var (
+	errSQLIsolationUnsupported = errors.New("sql: driver does not support non-default isolation level")
+	errSQLReadOnlyUnsupported  = errors.New("sql: driver does not support read-only transactions")
+)
+
+// SQLDriverOptions configures WrapDriver and OpenDB. Start from
+// NewSQLDriverOptions, a zero value disables slow query and N+1 warnings.
+type SQLDriverOptions struct {
+	// LogAll logs every statement at DEBUG, like GormLogger with
+	// gormlogger.Info. Failed statements are always logged at ERROR.
+	LogAll bool
+	// SlowThreshold logs statements running longer at WARNING. Zero disables
+	// it.
+	SlowThreshold time.Duration
+	// Params controls how bound values are logged. Statements are always
+	// logged parameterized.
+	Params SQLParamsMode
+	// ParamsHashKey is the HMAC key used with SQLParamsHash.
+	ParamsHashKey []byte
+	// NPlusOneThreshold, see GormLogger.NPlusOneThreshold.
+	NPlusOneThreshold int
+
+	// TxLevel is the level of the transaction begin and commit entries, and
+	// TxRollbackLevel the level of the rollback entries.
+	TxLevel         slog.Level
+	TxRollbackLevel slog.Level
+}
+
+// NewSQLDriverOptions returns the options used when none are given, mirroring
+// the defaults of NewGormLogger and NewGormTxPlugin.
+//
+//	options := logging.NewSQLDriverOptions()
+//	options.LogAll = true
+//	db, err := logging.OpenDB("pgx", dsn, logger, options)
+func NewSQLDriverOptions() *SQLDriverOptions {
+	return &SQLDriverOptions{
+		SlowThreshold:     defaultSlowThreshold,
+		Params:            SQLParamsRedact,
+		NPlusOneThreshold: defaultNPlusOneThreshold,
+		TxLevel:           DebugLevel,
+		TxRollbackLevel:   InfoLevel,
+	}
+}
+
+// WrapDriver returns a database/sql driver logging the statements and
+// transactions run through d, with the same behavior as GormLogger. Entries
+// are logged with the Logger of the statement context, if any, else with
+// logger.
+//
+//	sql.Register("logged-postgres", logging.WrapDriver(&pq.Driver{}, logger, nil))
+func WrapDriver(
+	d driver.Driver,
+	logger *Logger,
+	options *SQLDriverOptions,
+) driver.Driver {
+	if options == nil {
+		options = NewSQLDriverOptions()
+	}
+	return &loggingDriver{
+		Driver: d,
+		l:      logger,
+		o:      options,
+	}
+}
+
+// OpenDB opens a database like sql.Open, with the registered driver wrapped
+// by WrapDriver.
+//
+//	db, err := logging.OpenDB("pgx", dsn, logger, nil)
+//	dbx := sqlx.NewDb(db, "pgx")
+func OpenDB(
+	driverName string,
+	dataSourceName string,
+	logger *Logger,
+	options *SQLDriverOptions,
+) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+
+	connector, err := WrapDriver(d, logger, options).(driver.DriverContext).OpenConnector(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
+
+type loggingDriver struct {
+	driver.Driver
+	l *Logger
+	o *SQLDriverOptions
+}
+
+func (d *loggingDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &loggingConn{Conn: conn, driver: d}, nil
+}
+
+func (d *loggingDriver) OpenConnector(name string) (driver.Connector, error) {
+	var connector driver.Connector = dsnConnector{dsn: name, driver: d.Driver}
+	if dc, ok := d.Driver.(driver.DriverContext); ok {
+		var err error
+		if connector, err = dc.OpenConnector(name); err != nil {
+			return nil, err
+		}
+	}
+	return &loggingConnector{Connector: connector, driver: d}, nil
+}
+
+func (d *loggingDriver) tracer() sqlTracer {
+	return sqlTracer{
+		logErrors:         true,
+		logWarnings:       true,
+		logAll:            d.o.LogAll,
+		slowThreshold:     d.o.SlowThreshold,
+		parameterized:     true,
+		params:            d.o.Params,
+		hashKey:           d.o.ParamsHashKey,
+		nPlusOneThreshold: d.o.NPlusOneThreshold,
+	}
+}
+
+func (d *loggingDriver) logger(ctx context.Context) *Logger {
+	if log := LoggerFromContext(ctx); log != nil {
+		return log
+	}
+	return d.l
+}
+
+// dsnConnector is the driver.Connector for drivers not implementing
+// driver.DriverContext, as in database/sql.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type loggingConnector struct {
+	driver.Connector
+	driver *loggingDriver
+}
+
+func (c *loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &loggingConn{Conn: conn, driver: c.driver}, nil
+}
+
+func (c *loggingConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// loggingConn logs the statements run on a connection. database/sql uses a
+// connection for one goroutine at a time, so tx needs no locking.
+type loggingConn struct {
+	driver.Conn
+	driver *loggingDriver
+	tx     *loggingTx
+}
+
+func (c *loggingConn) trace(
+	ctx context.Context,
+	startTime time.Time,
+	query string,
+	args []driver.NamedValue,
+	rows func() int64,
+	err error,
+) {
+	if errors.Is(err, driver.ErrSkip) {
+		// database/sql retries with a prepared statement, logged instead.
+		return
+	}
+
+	var extra []any
+	if c.tx != nil {
+		c.tx.statements.Add(1)
+		extra = append(extra, String(logFieldDBTxID, c.tx.id))
+	}
+
+	c.driver.tracer().trace(ctx, c.driver.logger(ctx), time.Since(startTime), err, func() sqlQuery {
+		params := make([]any, len(args))
+		for i, arg := range args {
+			params[i] = arg.Value
+		}
+		return sqlQuery{sql: query, params: params, rows: rows()}
+	}, extra...)
+}
+
+func (c *loggingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *loggingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		c.trace(ctx, time.Now(), query, nil, unknownRows, err)
+		return nil, err
+	}
+	return &loggingStmt{Stmt: stmt, conn: c, query: query}, nil
+}
+
+func (c *loggingConn) ExecContext(
+	ctx context.Context,
+	query string,
+	args []driver.NamedValue,
+) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	startTime := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	c.trace(ctx, startTime, query, args, rowsAffected(result), err)
+	return result, err
+}
+
+func (c *loggingConn) QueryContext(
+	ctx context.Context,
+	query string,
+	args []driver.NamedValue,
+) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	startTime := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	c.trace(ctx, startTime, query, args, unknownRows, err)
+	return rows, err
+}
+
+func (c *loggingConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var (
+		tx  driver.Tx
+		err error
+	)
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		// Fallback for drivers without ConnBeginTx, which cannot honor the
+		// options database/sql would refuse for them.
+		switch {
+		case opts.Isolation != driver.IsolationLevel(sql.LevelDefault):
+			err = errSQLIsolationUnsupported
+		case opts.ReadOnly:
+			err = errSQLReadOnlyUnsupported
+		default:
+			tx, err = c.Conn.Begin()
+		}
+	}
+
+	log := c.driver.logger(ctx)
+	if err != nil {
+		log.LogPC(ctx, sqlCallerPC(), ErrorLevel, "sql transaction begin failed", Error(err))
+		return nil, err
+	}
+
+	c.tx = &loggingTx{
+		Tx:        tx,
+		conn:      c,
+		ctx:       ctx,
+		id:        newTxID(),
+		startTime: time.Now(),
+	}
+	log.LogPC(ctx, sqlCallerPC(), c.driver.o.TxLevel, "sql transaction begin", String(logFieldDBTxID, c.tx.id))
+	return c.tx, nil
+}
+
+func (c *loggingConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *loggingConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *loggingConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *loggingConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type loggingStmt struct {
+	driver.Stmt
+	conn  *loggingConn
+	query string
+}
+
+func (s *loggingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *loggingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	startTime := time.Now()
+	var (
+		result driver.Result
+		err    error
+	)
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		// Fallback for drivers without StmtExecContext.
+		result, err = s.Stmt.Exec(driverValues(args))
+	}
+	s.conn.trace(ctx, startTime, s.query, args, rowsAffected(result), err)
+	return result, err
+}
+
+func (s *loggingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *loggingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	startTime := time.Now()
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		// Fallback for drivers without StmtQueryContext.
+		rows, err = s.Stmt.Query(driverValues(args))
+	}
+	s.conn.trace(ctx, startTime, s.query, args, unknownRows, err)
+	return rows, err
+}
+
+func (s *loggingStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return s.conn.CheckNamedValue(nv)
+}
+
+type loggingTx struct {
+	driver.Tx
+	conn      *loggingConn
+	ctx       context.Context
+	id        string
+	startTime time.Time
+
+	statements atomic.Int64
+}
+
+func (tx *loggingTx) Commit() error {
+	tx.conn.tx = nil
+	err := tx.Tx.Commit()
+	if err != nil {
+		tx.log(ErrorLevel, "sql transaction commit failed", Error(err))
+	} else {
+		tx.log(tx.conn.driver.o.TxLevel, "sql transaction commit")
+	}
+	return err
+}
+
+func (tx *loggingTx) Rollback() error {
+	tx.conn.tx = nil
+	err := tx.Tx.Rollback()
+	if err != nil {
+		tx.log(ErrorLevel, "sql transaction rollback failed", Error(err))
+	} else {
+		tx.log(tx.conn.driver.o.TxRollbackLevel, "sql transaction rollback")
+	}
+	return err
+}
+
+func (tx *loggingTx) log(level slog.Level, msg string, args ...any) {
+	logTxEnd(tx.ctx, tx.conn.driver.logger(tx.ctx), level, msg, tx.id, tx.startTime, tx.statements.Load(), args...)
+}
+
+func unknownRows() int64 {
+	return -1
+}
+
+func rowsAffected(result driver.Result) func() int64 {
+	return func() int64 {
+		if result == nil {
+			return -1
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return -1
+		}
+		return rows
+	}
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+func driverValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
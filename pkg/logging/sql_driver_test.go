@@ -0,0 +1,165 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dentech-floss/logging/pkg/logging"
+	_ "github.com/glebarez/go-sqlite"
+)
+
+func TestOpenDB(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.DebugLevel,
+
+		Output: &buf,
+	})
+
+	options := logging.NewSQLDriverOptions()
+	options.LogAll = true
+	db, err := logging.OpenDB("sqlite", ":memory:", logger, options)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "CREATE TABLE patients (id INTEGER PRIMARY KEY, personal_number TEXT)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO patients (personal_number) VALUES (?)", "19850709-9805"); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	stmt, err := db.PrepareContext(ctx, "SELECT id FROM patients WHERE personal_number = ?")
+	if err != nil {
+		t.Fatalf("Failed to prepare: %v", err)
+	}
+	var id int
+	if err := stmt.QueryRowContext(ctx, "19850709-9805").Scan(&id); err != nil {
+		t.Fatalf("Failed to query: %v", err)
+	}
+	_ = stmt.Close()
+
+	_, _ = db.ExecContext(ctx, "SELECT * FROM missing")
+
+	if strings.Contains(buf.String(), "19850709-9805") {
+		t.Fatalf("Expected bound values to stay out of the log, got: %s", buf.String())
+	}
+
+	var messages []string
+	var txID string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse JSON log %q: %v", line, err)
+		}
+		msg, _ := entry["message"].(string)
+		messages = append(messages, msg)
+
+		switch msg {
+		case "sql transaction begin":
+			txID, _ = entry["db_tx_id"].(string)
+			if entry["severity"] != "DEBUG" {
+				t.Errorf("Expected the default DEBUG level for transactions, got: %v", entry)
+			}
+		case "sql transaction commit":
+			if entry["db_tx_id"] != txID || entry["db_statements"] != float64(1) {
+				t.Errorf("Expected commit of the transaction with 1 statement, got: %v", entry)
+			}
+		case "sql error trace":
+			if entry["severity"] != "ERROR" || entry["db_table"] != "missing" {
+				t.Errorf("Expected error entry for the missing table, got: %v", entry)
+			}
+		}
+		if strings.HasPrefix(msg, "sql ") && !strings.HasSuffix(entry["logging.googleapis.com/sourceLocation"].(map[string]any)["file"].(string), "sql_driver_test.go") {
+			t.Errorf("Expected the test as source location, got: %v", entry["logging.googleapis.com/sourceLocation"])
+		}
+	}
+
+	want := []string{
+		"sql debug trace",
+		"sql transaction begin",
+		"sql debug trace",
+		"sql transaction commit",
+		"sql debug trace",
+		"sql error trace",
+	}
+	if strings.Join(messages, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, messages)
+	}
+}
+
+// legacyDriver implements none of the optional context interfaces, in
+// particular not driver.ConnBeginTx.
+type legacyDriver struct{}
+
+func (legacyDriver) Open(string) (driver.Conn, error) { return legacyConn{}, nil }
+
+type legacyConn struct{}
+
+func (legacyConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (legacyConn) Close() error                        { return nil }
+func (legacyConn) Begin() (driver.Tx, error)           { return legacyTx{}, nil }
+
+type legacyTx struct{}
+
+func (legacyTx) Commit() error   { return nil }
+func (legacyTx) Rollback() error { return nil }
+
+func TestWrapDriverBeginTxOptions(t *testing.T) {
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.InfoLevel,
+
+		Output: &bytes.Buffer{},
+	})
+
+	sql.Register("logged-legacy", logging.WrapDriver(legacyDriver{}, logger, nil))
+	db, err := sql.Open("logged-legacy", "")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	for _, opts := range []*sql.TxOptions{
+		{Isolation: sql.LevelSerializable},
+		{ReadOnly: true},
+	} {
+		if tx, err := db.BeginTx(ctx, opts); err == nil || !strings.Contains(err.Error(), "driver does not support") {
+			t.Errorf("Expected %+v to be refused, got: %v", opts, err)
+			if tx != nil {
+				_ = tx.Rollback()
+			}
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Expected a default transaction to begin, got: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+}
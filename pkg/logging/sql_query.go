@@ -0,0 +1,269 @@
+package logging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	logFieldSQL              = "sql"
+	logFieldSQLParams        = "sql_params"
+	logFieldDBTable          = "db_table"
+	logFieldDBOperation      = "db_operation"
+	logFieldRows             = "rows"
+	logFieldQueryFingerprint = "query_fingerprint"
+)
+
+// Defaults of NewGormLogger, NewPgxTracer and NewSQLDriverOptions.
+const (
+	defaultSlowThreshold     = 200 * time.Millisecond
+	defaultNPlusOneThreshold = 10
+)
+
+// SQLParamsMode controls how the values bound to a parameterized query are
+// logged.
+type SQLParamsMode int
+
+const (
+	// SQLParamsRedact logs every bound value as "[REDACTED]", keeping only
+	// their number and which of them are NULL.
+	SQLParamsRedact SQLParamsMode = iota
+	// SQLParamsHash logs a hash of every bound value, so entries for the same
+	// values can be correlated without revealing them.
+	SQLParamsHash
+	// SQLParamsOmit leaves the bound values out.
+	SQLParamsOmit
+)
+
+var (
+	sqlStringLiteral     = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumericLiteral    = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	sqlNumberedParam     = regexp.MustCompile(`(?:\$|:|@p?)\d+\b`)
+	sqlPlaceholderList   = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	sqlValuesList        = regexp.MustCompile(`(\(\?\.\.\.\))(?:\s*,\s*\(\?\.\.\.\))+`)
+	sqlWhitespace        = regexp.MustCompile(`\s+`)
+	sqlTableAfterKeyword = regexp.MustCompile("(?i)\\b(?:from|into|update|join|table|copy)\\s+[`\"\\[]?([\\w.]+)")
+)
+
+// sqlQuery describes an executed statement for logging.
+type sqlQuery struct {
+	// sql is the statement as run, with placeholders, or with the values
+	// interpolated when no parameterized form is known.
+	sql    string
+	params []any
+	// table overrides the table found in sql, when known from elsewhere.
+	table       string
+	rows        int64
+	fingerprint string
+}
+
+// sqlTracer logs executed statements. It holds the behavior GormLogger and
+// the database/sql driver wrapper share.
+type sqlTracer struct {
+	logErrors         bool
+	logWarnings       bool
+	logAll            bool
+	slowThreshold     time.Duration
+	parameterized     bool
+	params            SQLParamsMode
+	hashKey           []byte
+	nPlusOneThreshold int
+}
+
+// trace logs a statement that ran for elapsed and failed with err, if any:
+// at ERROR when it failed, at WARNING when it was slow and at DEBUG
+// otherwise, as far as enabled. It also updates the QueryStats of the
+// context and warns about repeated queries. load is only called when needed,
+// and at most once.
+func (t sqlTracer) trace(
+	ctx context.Context,
+	logger *Logger,
+	elapsed time.Duration,
+	err error,
+	load func() sqlQuery,
+	extra ...any,
+) {
+	stats := QueryStatsFromContext(ctx)
+	if !t.logErrors && !t.logWarnings && !t.logAll && stats == nil {
+		return
+	}
+
+	var query *sqlQuery
+	loadQuery := func() sqlQuery {
+		if query == nil {
+			q := load()
+			query = &q
+		}
+		return *query
+	}
+	queryFields := func(fields ...any) []any {
+		fields = append(fields, sqlQueryFields(loadQuery(), t.parameterized, t.params, t.hashKey)...)
+		return append(fields, extra...)
+	}
+
+	if stats != nil {
+		count := stats.add(loadQuery().fingerprint, elapsed)
+		if t.nPlusOneThreshold > 0 && count == t.nPlusOneThreshold+1 && t.logWarnings {
+			logger.LogPC(
+				ctx,
+				sqlCallerPC(),
+				WarnLevel,
+				"sql repeated query trace, possible N+1",
+				queryFields(Int("count", count))...,
+			)
+		}
+	}
+
+	switch {
+	case err != nil && t.logErrors:
+		logger.LogPC(
+			ctx,
+			sqlCallerPC(),
+			ErrorLevel,
+			"sql error trace",
+			queryFields(Error(err), Duration("duration", elapsed))...,
+		)
+	case t.slowThreshold != 0 && elapsed > t.slowThreshold && t.logWarnings:
+		logger.LogPC(
+			ctx,
+			sqlCallerPC(),
+			WarnLevel,
+			"sql slow query trace",
+			queryFields(Duration("duration", elapsed))...,
+		)
+	case t.logAll:
+		logger.LogPC(
+			ctx,
+			sqlCallerPC(),
+			DebugLevel,
+			"sql debug trace",
+			queryFields(Duration("duration", elapsed))...,
+		)
+	}
+}
+
+// normalizeSQL replaces the literals and placeholders of a statement with
+// "?", collapses lists of them and the whitespace, so that statements
+// differing only in their values normalize to the same string.
+func normalizeSQL(sql string) string {
+	sql = sqlStringLiteral.ReplaceAllString(sql, "?")
+	sql = sqlNumberedParam.ReplaceAllString(sql, "?")
+	sql = sqlNumericLiteral.ReplaceAllString(sql, "?")
+	sql = sqlPlaceholderList.ReplaceAllString(sql, "(?...)")
+	sql = sqlValuesList.ReplaceAllString(sql, "$1")
+	return strings.TrimSpace(sqlWhitespace.ReplaceAllString(sql, " "))
+}
+
+// queryFingerprint identifies the normalized form of a statement, e.g. to
+// group slow queries.
+func queryFingerprint(sql string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(normalizeSQL(sql))))
+	return hex.EncodeToString(sum[:8])
+}
+
+// sqlOperation returns the verb of a statement, e.g. SELECT or INSERT.
+func sqlOperation(sql string) string {
+	verb, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	return strings.ToUpper(strings.TrimRight(verb, "(;"))
+}
+
+// sqlTable returns the first table a statement refers to, if any.
+func sqlTable(sql string) string {
+	if match := sqlTableAfterKeyword.FindStringSubmatch(sql); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// sqlQueryFields returns the structured fields describing a statement. With
+// parameterized set, no value is logged as part of the statement: it is
+// logged normalized, and the bound values according to mode.
+func sqlQueryFields(
+	query sqlQuery,
+	parameterized bool,
+	mode SQLParamsMode,
+	hashKey []byte,
+) []any {
+	sql := query.sql
+	if query.fingerprint == "" {
+		query.fingerprint = queryFingerprint(sql)
+	}
+	if parameterized {
+		sql = normalizeSQL(sql)
+	}
+
+	fields := []any{
+		Int64(logFieldRows, query.rows),
+		String(logFieldSQL, sql),
+		String(logFieldDBOperation, sqlOperation(sql)),
+		String(logFieldQueryFingerprint, query.fingerprint),
+	}
+	table := query.table
+	if table == "" {
+		table = sqlTable(sql)
+	}
+	if table != "" {
+		fields = append(fields, String(logFieldDBTable, table))
+	}
+	if parameterized && len(query.params) != 0 && mode != SQLParamsOmit {
+		fields = append(fields, slog.Any(logFieldSQLParams, sqlParams(query.params, mode, hashKey)))
+	}
+	return fields
+}
+
+func sqlParams(params []any, mode SQLParamsMode, hashKey []byte) []any {
+	result := make([]any, len(params))
+	for i, param := range params {
+		value := sqlParamValue(param)
+		switch {
+		case value == nil:
+			result[i] = nil
+		case mode == SQLParamsHash:
+			result[i] = hashSQLParam(value, hashKey)
+		default:
+			result[i] = defaultRedactionReplacement
+		}
+	}
+	return result
+}
+
+func sqlParamValue(param any) any {
+	if valuer, ok := param.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return err.Error()
+		}
+		return value
+	}
+	return param
+}
+
+// hashSQLParam returns a short HMAC-SHA256 of the value, or a plain SHA-256
+// without key. Prefer a key: values from a small domain, like personal
+// identity numbers, are easily recovered from an unkeyed hash.
+func hashSQLParam(value any, key []byte) string {
+	var h hash.Hash
+	if len(key) != 0 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	switch v := value.(type) {
+	case []byte:
+		h.Write(v)
+	case time.Time:
+		h.Write([]byte(v.UTC().Format(time.RFC3339Nano)))
+	default:
+		fmt.Fprint(h, v)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -1,43 +1,102 @@
 package logging
 
-import "github.com/ThreeDotsLabs/watermill"
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+type WatermillAdapterOptions struct {
+	// ErrorLevel, InfoLevel, DebugLevel and TraceLevel are the levels the
+	// corresponding Watermill methods log at. They default to ErrorLevel,
+	// InfoLevel, DebugLevel and TraceLevel.
+	ErrorLevel slog.Leveler
+	InfoLevel  slog.Leveler
+	DebugLevel slog.Leveler
+	TraceLevel slog.Leveler
+}
 
 type WatermillAdapter struct {
-	l *Logger
+	l   *Logger
+	o   *WatermillAdapterOptions
+	ctx context.Context
 }
 
 func NewWatermillAdapter(l *Logger) watermill.LoggerAdapter {
-	return &WatermillAdapter{l: l}
+	return NewWatermillAdapterWithOptions(l, nil)
+}
+
+func NewWatermillAdapterWithOptions(
+	l *Logger,
+	options *WatermillAdapterOptions,
+) *WatermillAdapter {
+	if options == nil {
+		options = &WatermillAdapterOptions{}
+	}
+	return &WatermillAdapter{l: l, o: options, ctx: context.Background()}
+}
+
+// WithContext returns an adapter for Watermill components that log per
+// message. It logs with the Logger found in ctx, if any, and passes ctx on,
+// so the entries are correlated with the trace and carry the fields of
+// ContextWithLoggerFields.
+func (wa *WatermillAdapter) WithContext(ctx context.Context) watermill.LoggerAdapter {
+	l := LoggerFromContext(ctx)
+	if l == nil {
+		l = wa.l
+	}
+	return &WatermillAdapter{l: l, o: wa.o, ctx: ctx}
 }
 
 func (wa *WatermillAdapter) Error(msg string, err error, fields watermill.LogFields) {
-	wa.l.Error(msg, append(slogAttrsFromFields(fields), Error(err))...)
+	wa.log(levelOrDefault(wa.o.ErrorLevel, ErrorLevel), msg, append(slogAttrsFromFields(fields), Error(err))...)
 }
 
 func (wa *WatermillAdapter) Info(msg string, fields watermill.LogFields) {
-	wa.l.Info(msg, slogAttrsFromFields(fields)...)
+	wa.log(levelOrDefault(wa.o.InfoLevel, InfoLevel), msg, slogAttrsFromFields(fields)...)
 }
 
 func (wa *WatermillAdapter) Debug(msg string, fields watermill.LogFields) {
-	wa.l.Debug(msg, slogAttrsFromFields(fields)...)
+	wa.log(levelOrDefault(wa.o.DebugLevel, DebugLevel), msg, slogAttrsFromFields(fields)...)
 }
 
 func (wa *WatermillAdapter) Trace(msg string, fields watermill.LogFields) {
-	wa.l.Info(msg, slogAttrsFromFields(fields)...)
+	wa.log(levelOrDefault(wa.o.TraceLevel, TraceLevel), msg, slogAttrsFromFields(fields)...)
 }
 
 func (wa *WatermillAdapter) With(fields watermill.LogFields) watermill.LoggerAdapter {
-	withLogger := wa.l.With(slogAttrsFromFields(fields)...)
+	withLogger := wa.l.With(attrsToAny(slogAttrsFromFields(fields))...)
+
+	return &WatermillAdapter{l: withLogger, o: wa.o, ctx: wa.ctx}
+}
 
-	return &WatermillAdapter{l: withLogger}
+func (wa *WatermillAdapter) log(level slog.Level, msg string, attrs ...slog.Attr) {
+	wa.l.LogAttrs(wa.ctx, level, msg, attrs...)
 }
 
-func slogAttrsFromFields(fields watermill.LogFields) []any {
-	result := make([]any, 0, len(fields)*2)
+// slogAttrsFromFields converts the fields sorted by key, so that they appear
+// in the same order on every entry.
+func slogAttrsFromFields(fields watermill.LogFields) []slog.Attr {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
 
-	for key, value := range fields {
-		result = append(result, key, value)
+	result := make([]slog.Attr, 0, len(fields))
+	for _, key := range keys {
+		result = append(result, slog.Any(key, fields[key]))
 	}
 
 	return result
 }
+
+func attrsToAny(attrs []slog.Attr) []any {
+	result := make([]any, len(attrs))
+	for i, attr := range attrs {
+		result[i] = attr
+	}
+	return result
+}
@@ -0,0 +1,184 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	logTypeValueIncomingMessage = "incoming_message"
+
+	logFieldMessageUUID = "message_uuid"
+	logFieldTopic       = "topic"
+	logFieldHandlerName = "handler_name"
+	logFieldOutcome     = "outcome"
+	logFieldAttempt     = "attempt"
+	logFieldRetries     = "retries"
+
+	outcomeAck  = "ack"
+	outcomeNack = "nack"
+)
+
+type WatermillMiddlewareOptions struct {
+	// Propagator extracts the trace context from the message metadata, and
+	// injects it into the produced messages. Defaults to
+	// otel.GetTextMapPropagator().
+	Propagator propagation.TextMapPropagator
+
+	// AckLevel is the level of the entry for a handled message. Defaults to
+	// INFO.
+	AckLevel slog.Leveler
+	// RetryLevel is the level of the entries for failed attempts logged by
+	// AttemptMiddleware. Defaults to WARNING.
+	RetryLevel slog.Leveler
+	// NackLevel is the level of the entry for a message that is nacked, i.e.
+	// its handler finally failed. Defaults to ERROR.
+	NackLevel slog.Leveler
+}
+
+// WatermillMiddleware logs the handling of messages by a Watermill router,
+// correlated with the trace the message was published in.
+//
+//	wm := logging.NewWatermillMiddleware(logger, nil)
+//	router.AddMiddleware(
+//		wm.Middleware,
+//		middleware.Retry{MaxRetries: 3}.Middleware,
+//		wm.AttemptMiddleware,
+//	)
+type WatermillMiddleware struct {
+	l *Logger
+	o *WatermillMiddlewareOptions
+}
+
+func NewWatermillMiddleware(
+	logger *Logger,
+	options *WatermillMiddlewareOptions,
+) *WatermillMiddleware {
+	if options == nil {
+		options = &WatermillMiddlewareOptions{}
+	}
+	return &WatermillMiddleware{
+		l: logger,
+		o: options,
+	}
+}
+
+// handlingState is shared by Middleware and AttemptMiddleware through the
+// message context, which Retry hands to every attempt.
+type handlingState struct {
+	attempts int
+}
+
+type handlingStateKey struct{}
+
+// Middleware extracts the trace context from the message metadata, puts a
+// Logger with the message UUID, topic and handler name into msg.Context()
+// (see LoggerFromContext) and emits one entry per message with the outcome,
+// duration and number of retries. Add it before any Retry middleware.
+func (wm *WatermillMiddleware) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		ctx := msg.Context()
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			ctx = wm.propagator().Extract(ctx, propagation.MapCarrier(msg.Metadata))
+		}
+
+		log := wm.l.With(
+			String(logFieldMessageUUID, msg.UUID),
+			String(logFieldTopic, message.SubscribeTopicFromCtx(ctx)),
+			String(logFieldHandlerName, message.HandlerNameFromCtx(ctx)),
+		)
+		state := &handlingState{}
+		ctx = context.WithValue(ContextWithLogger(ctx, log), handlingStateKey{}, state)
+		msg.SetContext(ctx)
+
+		startTime := time.Now()
+		produced, err := h(msg)
+		duration := time.Since(startTime)
+
+		fields := []slog.Attr{
+			Label("log_type", logTypeValueIncomingMessage),
+			Duration("duration", duration),
+			Int64("duration_ms", duration.Milliseconds()),
+		}
+		if state.attempts > 1 {
+			fields = append(fields, Int(logFieldRetries, state.attempts-1))
+		}
+		if err != nil {
+			fields = append(fields, String(logFieldOutcome, outcomeNack), Error(err))
+			log.LogAttrs(ctx, levelOrDefault(wm.o.NackLevel, ErrorLevel), "failed to handle message", fields...)
+			return produced, err
+		}
+		fields = append(fields, String(logFieldOutcome, outcomeAck))
+		log.LogAttrs(ctx, levelOrDefault(wm.o.AckLevel, InfoLevel), "handled message", fields...)
+
+		for _, p := range produced {
+			wm.injectTraceContext(ctx, p)
+		}
+		return produced, nil
+	}
+}
+
+// AttemptMiddleware counts the attempts to handle a message and logs every
+// failed one. Add it after Retry, so that it runs for each attempt.
+func (wm *WatermillMiddleware) AttemptMiddleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		state, ok := msg.Context().Value(handlingStateKey{}).(*handlingState)
+		if !ok {
+			state = &handlingState{}
+		}
+		state.attempts++
+
+		produced, err := h(msg)
+		if err != nil {
+			ctx := msg.Context()
+			log := LoggerFromContext(ctx)
+			if log == nil {
+				log = wm.l
+			}
+			log.LogAttrs(
+				ctx,
+				levelOrDefault(wm.o.RetryLevel, WarnLevel),
+				"failed attempt to handle message",
+				Label("log_type", logTypeValueIncomingMessage),
+				Int(logFieldAttempt, state.attempts),
+				Error(err),
+			)
+		}
+		return produced, err
+	}
+}
+
+func (wm *WatermillMiddleware) propagator() propagation.TextMapPropagator {
+	if wm.o.Propagator != nil {
+		return wm.o.Propagator
+	}
+	return otel.GetTextMapPropagator()
+}
+
+// injectTraceContext continues the trace in a produced message, unless its
+// metadata already carries one.
+func (wm *WatermillMiddleware) injectTraceContext(ctx context.Context, msg *message.Message) {
+	propagator := wm.propagator()
+	for _, field := range propagator.Fields() {
+		if msg.Metadata.Get(field) != "" {
+			return
+		}
+	}
+	if msg.Metadata == nil {
+		msg.Metadata = message.Metadata{}
+	}
+	propagator.Inject(ctx, propagation.MapCarrier(msg.Metadata))
+}
+
+func levelOrDefault(level slog.Leveler, fallback slog.Level) slog.Level {
+	if level == nil {
+		return fallback
+	}
+	return level.Level()
+}
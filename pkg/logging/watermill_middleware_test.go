@@ -0,0 +1,143 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	"github.com/dentech-floss/logging/pkg/logging"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestWatermillMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.InfoLevel,
+
+		Output: &buf,
+	})
+	wm := logging.NewWatermillMiddleware(logger, &logging.WatermillMiddlewareOptions{
+		Propagator: propagation.TraceContext{},
+	})
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+	router.AddMiddleware(
+		wm.Middleware,
+		middleware.Retry{MaxRetries: 1, InitialInterval: time.Millisecond}.Middleware,
+		wm.AttemptMiddleware,
+	)
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	handled := make(chan struct{})
+	attempts := 0
+	router.AddConsumerHandler("booking-handler", "bookings", pubSub, func(msg *message.Message) error {
+		if logging.LoggerFromContext(msg.Context()) == nil {
+			t.Errorf("Expected a logger in the message context")
+		}
+		if attempts++; attempts == 1 {
+			return errors.New("temporarily unavailable")
+		}
+		close(handled)
+		return nil
+	})
+
+	go func() { _ = router.Run(context.Background()) }()
+	<-router.Running()
+
+	msg := message.NewMessage(watermill.NewUUID(), []byte("{}"))
+	msg.Metadata.Set("traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01")
+	if err := pubSub.Publish("bookings", msg); err != nil {
+		t.Fatalf("Failed to publish: %v", err)
+	}
+
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Message was not handled")
+	}
+	if err := router.Close(); err != nil {
+		t.Fatalf("Failed to close router: %v", err)
+	}
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse JSON log %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected a failed attempt and a handled entry, got: %v", entries)
+	}
+
+	if entries[0]["severity"] != "WARNING" || entries[0]["attempt"] != float64(1) {
+		t.Errorf("Expected failed attempt at WARNING, got: %v", entries[0])
+	}
+
+	handledEntry := entries[1]
+	if handledEntry["message"] != "handled message" || handledEntry["outcome"] != "ack" || handledEntry["retries"] != float64(1) {
+		t.Errorf("Expected acked message after one retry, got: %v", handledEntry)
+	}
+	if handledEntry["message_uuid"] != msg.UUID || handledEntry["topic"] != "bookings" || handledEntry["handler_name"] != "booking-handler" {
+		t.Errorf("Expected message fields, got: %v", handledEntry)
+	}
+	for _, entry := range entries {
+		if entry["logging.googleapis.com/trace"] != "projects/test-project/traces/0102030405060708090a0b0c0d0e0f10" {
+			t.Errorf("Expected trace from the message metadata, got: %v", entry)
+		}
+	}
+}
+
+func TestWatermillAdapter(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := logging.NewLogger(&logging.LoggerConfig{
+		ProjectID:   "test-project",
+		ServiceName: "test-service",
+		MinLevel:    logging.DebugLevel,
+
+		Output: &buf,
+	})
+	adapter := logging.NewWatermillAdapterWithOptions(logger, nil)
+
+	adapter.Trace("not emitted", nil)
+	if buf.Len() != 0 {
+		t.Fatalf("Expected Trace below Debug to be dropped, got: %s", buf.String())
+	}
+
+	adapter.Debug("emitted", watermill.LogFields{"c": 3, "a": 1, "b": 2})
+	line := buf.String()
+	if a, b, c := strings.Index(line, `"a":`), strings.Index(line, `"b":`), strings.Index(line, `"c":`); a >= b || b >= c {
+		t.Errorf("Expected fields in sorted order, got: %s", line)
+	}
+
+	buf.Reset()
+	ctx := context.Background()
+	msg := message.NewMessage(watermill.NewUUID(), nil)
+	msg.Metadata.Set("traceparent", "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01")
+	ctx = propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier(msg.Metadata))
+
+	adapter.WithContext(ctx).Info("per message", nil)
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse JSON log: %v", err)
+	}
+	if entry["logging.googleapis.com/trace"] != "projects/test-project/traces/0102030405060708090a0b0c0d0e0f10" {
+		t.Errorf("Expected trace correlation, got: %v", entry)
+	}
+}